@@ -0,0 +1,84 @@
+package unbound
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// AddrRejection is a name from a reverse lookup that does not map back to the
+// address it was found for.
+type AddrRejection struct {
+	Name string
+	Err  error // Reason the name was rejected
+}
+
+// LookupAddrConfirmed performs a forward-confirmed reverse lookup for the
+// given address: each name returned by the reverse lookup is resolved to
+// its addresses, A for IPv4 and AAAA for IPv6, and only the names that map
+// back to addr are returned. The other names are returned as rejected,
+// together with the reason.
+// This method is not found in Unbound.
+func (u *Unbound) LookupAddrConfirmed(addr string) (names []string, rejected []AddrRejection, err error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, nil, errors.New("unbound: invalid address: " + addr)
+	}
+	reverse, err := dns.ReverseAddr(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := u.Resolve(reverse, dns.TypePTR, dns.ClassINET)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.Bogus {
+		return nil, nil, fmt.Errorf("unbound: PTR records are bogus for %s: %s", addr, r.WhyBogus)
+	}
+	for _, rr := range r.Rr {
+		name := rr.(*dns.PTR).Ptr
+		if err := u.confirmAddr(name, ip); err != nil {
+			rejected = append(rejected, AddrRejection{Name: name, Err: err})
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, rejected, nil
+}
+
+// confirmAddr returns an error if name does not resolve to ip.
+func (u *Unbound) confirmAddr(name string, ip net.IP) error {
+	qtype := dns.TypeA
+	if ip.To4() == nil {
+		qtype = dns.TypeAAAA
+	}
+	r, err := u.Resolve(name, qtype, dns.ClassINET)
+	if err != nil {
+		return err
+	}
+	switch {
+	case r.Bogus:
+		return fmt.Errorf("%s records are bogus: %s", dns.TypeToString[qtype], r.WhyBogus)
+	case r.NxDomain:
+		return errors.New("name does not exist")
+	case r.Rcode != dns.RcodeSuccess:
+		return fmt.Errorf("%s lookup failed: %s", dns.TypeToString[qtype], dns.RcodeToString[r.Rcode])
+	case len(r.Rr) == 0:
+		return fmt.Errorf("no %s records", dns.TypeToString[qtype])
+	}
+	for _, rr := range r.Rr {
+		switch x := rr.(type) {
+		case *dns.A:
+			if x.A.Equal(ip) {
+				return nil
+			}
+		case *dns.AAAA:
+			if x.AAAA.Equal(ip) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s records do not include %s", dns.TypeToString[qtype], ip)
+}
@@ -0,0 +1,56 @@
+package unbound
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestLookupAddrConfirmed(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+	for _, rr := range []string{
+		`1.2.0.192.in-addr.arpa. PTR good.example.`,
+		`1.2.0.192.in-addr.arpa. PTR other.example.`,
+		`1.2.0.192.in-addr.arpa. PTR gone.example.`,
+		`good.example. A 192.0.2.1`,
+		`other.example. A 192.0.2.99`,
+		`1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa. PTR good6.example.`,
+		`good6.example. AAAA 2001:db8::1`,
+	} {
+		if err := u.DataAdd(rr); err != nil {
+			t.Fatalf("failed to add local data: %s", err)
+		}
+	}
+	if _, err := u.Resolve("good.example.", dns.TypeA, dns.ClassINET); err != nil {
+		t.Skipf("can not resolve local data: %s", err)
+	}
+
+	names, rejected, err := u.LookupAddrConfirmed("192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "good.example." {
+		t.Errorf("expected good.example., got %v", names)
+	}
+	if len(rejected) != 2 {
+		t.Fatalf("expected 2 rejected names, got %v", rejected)
+	}
+	for _, r := range rejected {
+		if r.Err == nil {
+			t.Errorf("expected reason for rejecting %s", r.Name)
+		}
+	}
+
+	names, _, err = u.LookupAddrConfirmed("2001:db8::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "good6.example." {
+		t.Errorf("expected good6.example., got %v", names)
+	}
+
+	if _, _, err := u.LookupAddrConfirmed("not an address"); err == nil {
+		t.Error("expected error for invalid address")
+	}
+}
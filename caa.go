@@ -0,0 +1,110 @@
+package unbound
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// CAASet is the relevant CAA record set for a name, see RFC 8659.
+type CAASet struct {
+	Name   string     // Name the records were found at, empty when there are none
+	CAA    []*dns.CAA // The records, empty when issuance is not restricted
+	Secure bool       // True if the answer was validated
+	Bogus  bool       // True if the answer failed validation
+}
+
+// LookupCAA returns the relevant CAA record set for name. It climbs from name
+// towards the root, excluding the root itself, and stops at the first name
+// that has CAA records. Aliases are followed by the resolver. The climb stops
+// at a bogus answer, which is returned with Bogus set.
+func (u *Unbound) LookupCAA(name string) (*CAASet, error) {
+	name = strings.TrimPrefix(dns.Fqdn(name), "*.")
+	labels := dns.SplitDomainName(name)
+	for i := range labels {
+		qname := dns.Fqdn(strings.Join(labels[i:], "."))
+		r, err := u.Resolve(qname, dns.TypeCAA, dns.ClassINET)
+		if err != nil {
+			return nil, err
+		}
+		if r.Bogus {
+			return &CAASet{Name: qname, Bogus: true}, nil
+		}
+		if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+			return nil, fmt.Errorf("unbound: CAA lookup for %s failed: %s", qname, dns.RcodeToString[r.Rcode])
+		}
+		var caa []*dns.CAA
+		for _, rr := range r.Rr {
+			if x, ok := rr.(*dns.CAA); ok {
+				caa = append(caa, x)
+			}
+		}
+		if len(caa) > 0 {
+			return &CAASet{Name: qname, CAA: caa, Secure: r.Secure}, nil
+		}
+	}
+	return &CAASet{}, nil
+}
+
+// CAAPermits returns true if the CA identified by issuerDomain may issue a
+// certificate for name, see RFC 8659 section 4. When wildcard is true the
+// decision is for a wildcard certificate, i.e. *.name. An error is returned
+// if the relevant record set could not be found or is bogus; no decision is
+// made in those cases.
+// This method is not found in Unbound.
+func (u *Unbound) CAAPermits(name, issuerDomain string, wildcard bool) (bool, error) {
+	set, err := u.LookupCAA(name)
+	if err != nil {
+		return false, err
+	}
+	if set.Bogus {
+		return false, errors.New("unbound: CAA record set is bogus for: " + set.Name)
+	}
+	return set.permits(issuerDomain, wildcard), nil
+}
+
+// permits implements the issuance decision of RFC 8659 section 4.
+func (set *CAASet) permits(issuerDomain string, wildcard bool) bool {
+	tag := "issue"
+	for _, c := range set.CAA {
+		switch t := strings.ToLower(c.Tag); t {
+		case "issue", "iodef":
+		case "issuewild":
+			if wildcard {
+				tag = t
+			}
+		default:
+			// Unknown property with the issuer critical flag set.
+			if c.Flag&128 != 0 {
+				return false
+			}
+		}
+	}
+
+	relevant := false
+	for _, c := range set.CAA {
+		if !strings.EqualFold(c.Tag, tag) {
+			continue
+		}
+		relevant = true
+		if issuerDomain != "" && strings.EqualFold(caaIssuer(c.Value), dns.Fqdn(issuerDomain)) {
+			return true
+		}
+	}
+	return !relevant
+}
+
+// caaIssuer returns the fully qualified issuer domain name of the value of an
+// issue or issuewild property. It returns "." when the value names no issuer.
+func caaIssuer(value string) string {
+	if i := strings.IndexByte(value, ';'); i >= 0 {
+		value = value[:i]
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "."
+	}
+	return dns.Fqdn(value)
+}
@@ -0,0 +1,44 @@
+package unbound
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestCAAPermits(t *testing.T) {
+	caa := func(s ...string) *CAASet {
+		set := &CAASet{Name: "example.com."}
+		for _, r := range s {
+			rr, err := dns.NewRR("example.com. IN CAA " + r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			set.CAA = append(set.CAA, rr.(*dns.CAA))
+		}
+		return set
+	}
+	tests := []struct {
+		set      *CAASet
+		issuer   string
+		wildcard bool
+		permit   bool
+	}{
+		{caa(), "ca.example.net", false, true},
+		{caa(`0 issue "ca.example.net"`), "ca.example.net", false, true},
+		{caa(`0 issue "ca.example.net; account=230123"`), "CA.example.net.", false, true},
+		{caa(`0 issue "ca.example.net"`), "other.example.org", false, false},
+		{caa(`0 issue ";"`), "ca.example.net", false, false},
+		{caa(`0 iodef "mailto:security@example.com"`), "ca.example.net", false, true},
+		{caa(`0 issue "ca.example.net"`, `0 issuewild ";"`), "ca.example.net", true, false},
+		{caa(`0 issue "ca.example.net"`, `0 issuewild ";"`), "ca.example.net", false, true},
+		{caa(`0 issue "other.example.org"`), "ca.example.net", true, false},
+		{caa(`0 issue "ca.example.net"`, `128 tbs "unknown"`), "ca.example.net", false, false},
+		{caa(`0 issue "ca.example.net"`, `0 tbs "unknown"`), "ca.example.net", false, true},
+	}
+	for i, tc := range tests {
+		if permit := tc.set.permits(tc.issuer, tc.wildcard); permit != tc.permit {
+			t.Errorf("test %d: expected permit %t, got %t", i, tc.permit, permit)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package unbound
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// TLSA certificate usages, selectors and matching types, see RFC 7218.
+const (
+	TLSAPKIXTA = 0 // Usage PKIX-TA: CA constraint
+	TLSAPKIXEE = 1 // Usage PKIX-EE: service certificate constraint
+	TLSADANETA = 2 // Usage DANE-TA: trust anchor assertion
+	TLSADANEEE = 3 // Usage DANE-EE: domain issued certificate
+
+	TLSACert = 0 // Selector Cert: full certificate
+	TLSASPKI = 1 // Selector SPKI: subject public key info
+
+	TLSAFull     = 0 // Matching type Full: exact match
+	TLSASHA2_256 = 1 // Matching type SHA2-256
+	TLSASHA2_512 = 2 // Matching type SHA2-512
+)
+
+// Errors returned by DANE verification.
+var (
+	ErrTLSABogus    = errors.New("unbound: TLSA records are bogus")
+	ErrNoTLSA       = errors.New("unbound: no usable secure TLSA records")
+	ErrTLSAMismatch = errors.New("unbound: no TLSA record matches the certificate chain")
+)
+
+// DANEVerifier verifies TLS connections with DANE, RFC 6698 and RFC 7671.
+// Only TLSA records from a secure answer are used. When there are no usable
+// secure TLSA records, the connection is verified with PKIX against Roots,
+// unless RequireDANE is set, in which case it fails with ErrNoTLSA. Bogus
+// TLSA records always fail the connection with ErrTLSABogus.
+//
+// As DANE-EE and DANE-TA certificates need not chain to a public root, the
+// tls.Config must have InsecureSkipVerify set, VerifyConnection does all
+// verification. Use TLSConfig to get such a configuration.
+type DANEVerifier struct {
+	Unbound     *Unbound
+	Port        int            // Port of the service, e.g. 443
+	Proto       string         // Transport protocol of the service, "tcp" when empty
+	Roots       *x509.CertPool // Roots for PKIX verification, the system roots when nil
+	RequireDANE bool           // Fail instead of falling back to PKIX without usable TLSA records
+}
+
+// NewDANEVerifier returns a DANEVerifier for TCP services on port.
+func NewDANEVerifier(u *Unbound, port int) *DANEVerifier {
+	return &DANEVerifier{Unbound: u, Port: port, Proto: "tcp"}
+}
+
+// TLSConfig returns a tls.Config for connecting to serverName that is
+// verified by v.
+func (v *DANEVerifier) TLSConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+		VerifyConnection:   v.VerifyConnection,
+	}
+}
+
+// VerifyConnection verifies the peer certificates of cs for cs.ServerName.
+// It can be used as tls.Config.VerifyConnection.
+func (v *DANEVerifier) VerifyConnection(cs tls.ConnectionState) error {
+	_, err := v.Verify(cs.ServerName, cs.PeerCertificates)
+	return err
+}
+
+// Verify verifies the certificate chain certs, leaf first, as presented by
+// the service on name. It returns the TLSA record that matched, or nil when
+// the chain was verified with PKIX because there are no usable TLSA records.
+func (v *DANEVerifier) Verify(name string, certs []*x509.Certificate) (*dns.TLSA, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("unbound: no certificates presented")
+	}
+	tlsa, err := v.lookup(name)
+	if err == ErrNoTLSA && !v.RequireDANE {
+		return nil, verifyPKIX(certs, name, v.Roots)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return verifyTLSA(tlsa, certs, []string{name}, v.Roots)
+}
+
+// lookup returns the usable TLSA records for name from a secure answer.
+func (v *DANEVerifier) lookup(name string) ([]*dns.TLSA, error) {
+	proto := v.Proto
+	if proto == "" {
+		proto = "tcp"
+	}
+	return v.Unbound.secureTLSA(dns.Fqdn(name), strconv.Itoa(v.Port), proto)
+}
+
+// secureTLSA returns the usable TLSA records for _port._proto.name. It returns
+// ErrNoTLSA when the answer is insecure or has no usable records and
+// ErrTLSABogus when the answer is bogus.
+func (u *Unbound) secureTLSA(name, port, proto string) ([]*dns.TLSA, error) {
+	tlsaname, err := dns.TLSAName(name, port, proto)
+	if err != nil {
+		return nil, err
+	}
+	r, err := u.Resolve(tlsaname, dns.TypeTLSA, dns.ClassINET)
+	if err != nil {
+		return nil, err
+	}
+	if r.Bogus {
+		return nil, ErrTLSABogus
+	}
+	if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("unbound: TLSA lookup for %s failed: %s", tlsaname, dns.RcodeToString[r.Rcode])
+	}
+	if !r.Secure {
+		return nil, ErrNoTLSA
+	}
+	var tlsa []*dns.TLSA
+	for _, rr := range r.Rr {
+		if x, ok := rr.(*dns.TLSA); ok && usableTLSA(x) {
+			tlsa = append(tlsa, x)
+		}
+	}
+	if len(tlsa) == 0 {
+		return nil, ErrNoTLSA
+	}
+	return tlsa, nil
+}
+
+// usableTLSA returns true if the usage, selector and matching type of rr are
+// known.
+func usableTLSA(rr *dns.TLSA) bool {
+	return rr.Usage <= TLSADANEEE && rr.Selector <= TLSASPKI && rr.MatchingType <= TLSASHA2_512
+}
+
+// verifyTLSA verifies the chain certs, leaf first, against the TLSA records.
+// The leaf must be valid for one of names for all usages except DANE-EE. It
+// returns the first record that matched.
+func verifyTLSA(tlsa []*dns.TLSA, certs []*x509.Certificate, names []string, roots *x509.CertPool) (*dns.TLSA, error) {
+	for _, rr := range tlsa {
+		if checkTLSA(rr, certs, names, roots) == nil {
+			return rr, nil
+		}
+	}
+	return nil, ErrTLSAMismatch
+}
+
+// checkTLSA verifies the chain certs, leaf first, against rr. The returned
+// error tells why the chain does not match.
+func checkTLSA(rr *dns.TLSA, certs []*x509.Certificate, names []string, roots *x509.CertPool) error {
+	if !usableTLSA(rr) {
+		return fmt.Errorf("unusable record: usage %d, selector %d, matching type %d", rr.Usage, rr.Selector, rr.MatchingType)
+	}
+	leaf := certs[0]
+	switch rr.Usage {
+	case TLSADANEEE:
+		// No name or validity checks, RFC 7671 section 5.1.
+		if matchTLSA(rr, leaf) {
+			return nil
+		}
+		return errors.New("does not match the leaf certificate")
+	case TLSADANETA:
+		// Try the leaf last, a self signed leaf may be its own trust anchor.
+		var err error
+		for i := 1; i <= len(certs); i++ {
+			ta := certs[i%len(certs)]
+			if !matchTLSA(rr, ta) {
+				continue
+			}
+			if err = verifyChain(certs, ta, names); err == nil {
+				return nil
+			}
+			err = fmt.Errorf("matches certificate %d, but the chain does not verify: %s", i%len(certs), err)
+		}
+		if err == nil {
+			err = errors.New("does not match any certificate in the chain")
+		}
+		return err
+	}
+
+	chains, err := verifyPKIXChains(certs, names, roots)
+	if err != nil {
+		return fmt.Errorf("PKIX verification failed: %s", err)
+	}
+	for _, chain := range chains {
+		if rr.Usage == TLSAPKIXEE {
+			if matchTLSA(rr, chain[0]) {
+				return nil
+			}
+			continue
+		}
+		for _, c := range chain[1:] {
+			if matchTLSA(rr, c) {
+				return nil
+			}
+		}
+	}
+	if rr.Usage == TLSAPKIXEE {
+		return errors.New("does not match the leaf certificate")
+	}
+	return errors.New("does not match any CA certificate in the verified chain")
+}
+
+// matchTLSA returns true if cert matches the selector, matching type and
+// association data of rr.
+func matchTLSA(rr *dns.TLSA, cert *x509.Certificate) bool {
+	data, err := dns.CertificateToDANE(rr.Selector, rr.MatchingType, cert)
+	return err == nil && strings.EqualFold(data, rr.Certificate)
+}
+
+// verifyChain verifies the leaf of certs for one of names with ta as the only
+// trust anchor. The other certificates are used as intermediates.
+func verifyChain(certs []*x509.Certificate, ta *x509.Certificate, names []string) error {
+	roots := x509.NewCertPool()
+	roots.AddCert(ta)
+	if certs[0] == ta {
+		return verifyNames(certs[0], names)
+	}
+	_, err := verifyPKIXChains(certs, names, roots)
+	return err
+}
+
+// verifyPKIX verifies the leaf of certs for name with PKIX against roots.
+func verifyPKIX(certs []*x509.Certificate, name string, roots *x509.CertPool) error {
+	_, err := verifyPKIXChains(certs, []string{name}, roots)
+	return err
+}
+
+// verifyPKIXChains verifies the leaf of certs for one of names against roots
+// and returns the verified chains.
+func verifyPKIXChains(certs []*x509.Certificate, names []string, roots *x509.CertPool) ([][]*x509.Certificate, error) {
+	inter := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		inter.AddCert(c)
+	}
+	opts := x509.VerifyOptions{Roots: roots, Intermediates: inter}
+	chains, err := certs[0].Verify(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyNames(certs[0], names); err != nil {
+		return nil, err
+	}
+	return chains, nil
+}
+
+// verifyNames returns nil if cert is valid for one of names.
+func verifyNames(cert *x509.Certificate, names []string) error {
+	err := errors.New("unbound: no names to verify certificate against")
+	for _, name := range names {
+		if err = cert.VerifyHostname(strings.TrimSuffix(name, ".")); err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+package unbound
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// newTestChain returns a leaf certificate for name issued by a new CA,
+// followed by the CA certificate.
+func newTestChain(t *testing.T, name string) []*x509.Certificate {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ = x509.ParseCertificate(caDER)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leaf, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ = x509.ParseCertificate(leafDER)
+	return []*x509.Certificate{leaf, ca}
+}
+
+func newTestTLSA(t *testing.T, usage, selector, matching uint8, cert *x509.Certificate) *dns.TLSA {
+	rr := &dns.TLSA{Hdr: dns.RR_Header{Name: "_443._tcp.www.example.", Rrtype: dns.TypeTLSA, Class: dns.ClassINET}}
+	if err := rr.Sign(int(usage), int(selector), int(matching), cert); err != nil {
+		t.Fatal(err)
+	}
+	return rr
+}
+
+func TestVerifyTLSA(t *testing.T) {
+	certs := newTestChain(t, "www.example")
+	other := newTestChain(t, "www.example")
+	names := []string{"www.example."}
+
+	tests := []struct {
+		tlsa  *dns.TLSA
+		names []string
+		match bool
+	}{
+		{newTestTLSA(t, TLSADANEEE, TLSASPKI, TLSASHA2_256, certs[0]), names, true},
+		{newTestTLSA(t, TLSADANEEE, TLSACert, TLSASHA2_512, certs[0]), []string{"other.example."}, true},
+		{newTestTLSA(t, TLSADANEEE, TLSASPKI, TLSASHA2_256, other[0]), names, false},
+		{newTestTLSA(t, TLSADANETA, TLSACert, TLSAFull, certs[1]), names, true},
+		{newTestTLSA(t, TLSADANETA, TLSASPKI, TLSASHA2_256, certs[1]), []string{"other.example."}, false},
+		{newTestTLSA(t, TLSADANETA, TLSASPKI, TLSASHA2_256, other[1]), names, false},
+		// PKIX usages need a chain to a root in the pool.
+		{newTestTLSA(t, TLSAPKIXEE, TLSASPKI, TLSASHA2_256, certs[0]), names, false},
+	}
+	for i, tc := range tests {
+		rr, err := verifyTLSA([]*dns.TLSA{tc.tlsa}, certs, tc.names, x509.NewCertPool())
+		if tc.match && (err != nil || rr != tc.tlsa) {
+			t.Errorf("test %d: expected match, got %v", i, err)
+		}
+		if !tc.match && err == nil {
+			t.Errorf("test %d: expected no match", i)
+		}
+	}
+}
+
+func TestVerifyTLSAPKIX(t *testing.T) {
+	certs := newTestChain(t, "www.example")
+	roots := x509.NewCertPool()
+	roots.AddCert(certs[1])
+
+	for _, rr := range []*dns.TLSA{
+		newTestTLSA(t, TLSAPKIXEE, TLSASPKI, TLSASHA2_256, certs[0]),
+		newTestTLSA(t, TLSAPKIXTA, TLSACert, TLSASHA2_256, certs[1]),
+	} {
+		if _, err := verifyTLSA([]*dns.TLSA{rr}, certs, []string{"www.example."}, roots); err != nil {
+			t.Errorf("expected usage %d to match, got %s", rr.Usage, err)
+		}
+	}
+}
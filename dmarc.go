@@ -0,0 +1,278 @@
+package unbound
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/publicsuffix"
+)
+
+// DMARCRecord is a DMARC policy record, see RFC 7489.
+type DMARCRecord struct {
+	Domain          string            // Domain the record was found at, the organizational domain when the fallback was used
+	Policy          string            // Requested policy (p): "none", "quarantine" or "reject"
+	SubdomainPolicy string            // Policy for subdomains (sp), defaults to Policy
+	Percent         int               // Percentage of messages the policy applies to (pct), defaults to 100
+	RUA             []string          // URIs for aggregate reports (rua)
+	ADKIM           string            // DKIM identifier alignment (adkim): "r" for relaxed or "s" for strict
+	ASPF            string            // SPF identifier alignment (aspf): "r" for relaxed or "s" for strict
+	Tags            map[string]string // All tags of the record
+	Secure          bool              // True if the answer was DNSSEC secure
+}
+
+// LookupDMARC returns the DMARC record for domain, see RFC 7489 section 6.6.3.
+// If domain has no record, the record of its organizational domain, as
+// determined by the public suffix list, is returned. If neither has a
+// record, the record returned is nil.
+// This method is not found in Unbound.
+func (u *Unbound) LookupDMARC(domain string) (*DMARCRecord, error) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	d, err := u.lookupDMARC(domain)
+	if d != nil || err != nil {
+		return d, err
+	}
+	org, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil || org == domain {
+		return nil, nil
+	}
+	return u.lookupDMARC(org)
+}
+
+// lookupDMARC returns the DMARC record published at _dmarc.domain, or nil if
+// there is none.
+func (u *Unbound) lookupDMARC(domain string) (*DMARCRecord, error) {
+	r, txt, err := u.lookupTXT("_dmarc." + domain + ".")
+	if err != nil {
+		return nil, err
+	}
+	if r.Bogus {
+		return nil, fmt.Errorf("unbound: DMARC record is bogus for %s: %s", domain, r.WhyBogus)
+	}
+	if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("unbound: TXT lookup for _dmarc.%s failed: %s", domain, dns.RcodeToString[r.Rcode])
+	}
+	record := ""
+	for _, rr := range txt {
+		s := strings.Join(rr.Txt, "")
+		if !strings.HasPrefix(s, "v=DMARC1") {
+			continue
+		}
+		if record != "" {
+			return nil, errors.New("unbound: multiple DMARC records for: " + domain)
+		}
+		record = s
+	}
+	if record == "" {
+		return nil, nil
+	}
+	d, err := parseDMARC(record)
+	if err != nil {
+		return nil, fmt.Errorf("unbound: malformed DMARC record for %s: %s", domain, err)
+	}
+	d.Domain = domain
+	d.Secure = r.Secure
+	return d, nil
+}
+
+// parseDMARC parses the DMARC record s.
+func parseDMARC(s string) (*DMARCRecord, error) {
+	tags, err := parseTagList(s)
+	if err != nil {
+		return nil, err
+	}
+	if tags["v"] != "DMARC1" {
+		return nil, errors.New("version is not DMARC1")
+	}
+	d := &DMARCRecord{Percent: 100, ADKIM: "r", ASPF: "r", Tags: tags}
+	if rua, ok := tags["rua"]; ok {
+		for _, uri := range strings.Split(rua, ",") {
+			if uri = strings.TrimSpace(uri); uri != "" {
+				d.RUA = append(d.RUA, uri)
+			}
+		}
+	}
+	d.Policy = strings.ToLower(tags["p"])
+	if !validDMARCPolicy(d.Policy) {
+		// A record with a valid rua tag, but without a valid policy is
+		// treated as having p=none, RFC 7489 section 6.6.3.
+		if len(d.RUA) == 0 {
+			return nil, errors.New("invalid policy: " + tags["p"])
+		}
+		d.Policy = "none"
+	}
+	d.SubdomainPolicy = d.Policy
+	if sp, ok := tags["sp"]; ok {
+		if sp = strings.ToLower(sp); validDMARCPolicy(sp) {
+			d.SubdomainPolicy = sp
+		}
+	}
+	if pct, ok := tags["pct"]; ok {
+		if d.Percent, err = strconv.Atoi(pct); err != nil || d.Percent < 0 || d.Percent > 100 {
+			return nil, errors.New("invalid percentage: " + pct)
+		}
+	}
+	for tag, p := range map[string]*string{"adkim": &d.ADKIM, "aspf": &d.ASPF} {
+		v, ok := tags[tag]
+		if !ok {
+			continue
+		}
+		if v = strings.ToLower(v); v != "r" && v != "s" {
+			return nil, fmt.Errorf("invalid %s alignment: %s", tag, v)
+		}
+		*p = v
+	}
+	return d, nil
+}
+
+func validDMARCPolicy(p string) bool {
+	return p == "none" || p == "quarantine" || p == "reject"
+}
+
+// DKIMKey is a DKIM public key record, see RFC 6376 section 3.6.1.
+type DKIMKey struct {
+	Selector  string
+	Domain    string
+	KeyType   string            // Key type (k): "rsa" or "ed25519", defaults to "rsa"
+	HashAlgs  []string          // Acceptable hash algorithms (h), empty if all are allowed
+	Services  []string          // Service types (s), defaults to "*"
+	Flags     []string          // Flags (t), e.g. "y" for testing mode
+	PublicKey crypto.PublicKey  // The key (p), nil if the key is revoked
+	Tags      map[string]string // All tags of the record
+	Secure    bool              // True if the answer was DNSSEC secure
+}
+
+// Revoked returns true if the key has been revoked, i.e. the p tag is empty.
+func (k *DKIMKey) Revoked() bool { return k.PublicKey == nil }
+
+// LookupDKIMKey returns the DKIM public key published for selector at domain,
+// i.e. the TXT record at selector._domainkey.domain. If there is no record,
+// the key returned is nil.
+// This method is not found in Unbound.
+func (u *Unbound) LookupDKIMKey(selector, domain string) (*DKIMKey, error) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	name := selector + "._domainkey." + domain + "."
+	if _, ok := dns.IsDomainName(name); !ok {
+		return nil, errors.New("unbound: invalid DKIM selector or domain: " + name)
+	}
+	r, txt, err := u.lookupTXT(name)
+	if err != nil {
+		return nil, err
+	}
+	if r.Bogus {
+		return nil, fmt.Errorf("unbound: DKIM key is bogus for %s: %s", name, r.WhyBogus)
+	}
+	// A failed lookup is a temporary failure, not a missing key, RFC 6376
+	// section 6.1.2.
+	if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("unbound: TXT lookup for %s failed: %s", name, dns.RcodeToString[r.Rcode])
+	}
+	switch len(txt) {
+	case 0:
+		return nil, nil
+	case 1:
+	default:
+		return nil, errors.New("unbound: multiple DKIM key records for: " + name)
+	}
+	k, err := parseDKIMKey(strings.Join(txt[0].Txt, ""))
+	if err != nil {
+		return nil, fmt.Errorf("unbound: malformed DKIM key record for %s: %s", name, err)
+	}
+	k.Selector, k.Domain, k.Secure = selector, domain, r.Secure
+	return k, nil
+}
+
+// parseDKIMKey parses the DKIM key record s.
+func parseDKIMKey(s string) (*DKIMKey, error) {
+	tags, err := parseTagList(s)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		return nil, errors.New("version is not DKIM1")
+	}
+	k := &DKIMKey{KeyType: "rsa", Services: []string{"*"}, Tags: tags}
+	if v, ok := tags["k"]; ok {
+		k.KeyType = strings.ToLower(v)
+	}
+	if v, ok := tags["h"]; ok {
+		k.HashAlgs = splitColon(v)
+	}
+	if v, ok := tags["s"]; ok {
+		k.Services = splitColon(v)
+	}
+	if v, ok := tags["t"]; ok {
+		k.Flags = splitColon(v)
+	}
+	p, ok := tags["p"]
+	if !ok {
+		return nil, errors.New("missing public key")
+	}
+	p = strings.Join(strings.Fields(p), "")
+	if p == "" {
+		return k, nil
+	}
+	der, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		return nil, err
+	}
+	switch k.KeyType {
+	case "rsa":
+		if k.PublicKey, err = x509.ParsePKIXPublicKey(der); err != nil {
+			// Some publish the bare RSAPublicKey instead of the
+			// SubjectPublicKeyInfo.
+			if k.PublicKey, err = x509.ParsePKCS1PublicKey(der); err != nil {
+				return nil, err
+			}
+		}
+	case "ed25519":
+		if len(der) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key size")
+		}
+		k.PublicKey = ed25519.PublicKey(der)
+	default:
+		return nil, errors.New("unknown key type: " + k.KeyType)
+	}
+	return k, nil
+}
+
+// parseTagList parses a list of tag=value pairs separated by semicolons, as
+// used by DKIM and DMARC, RFC 6376 section 3.2. Duplicate tags are an error.
+func parseTagList(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, spec := range strings.Split(s, ";") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		i := strings.IndexByte(spec, '=')
+		if i < 0 {
+			return nil, errors.New("missing '=' in tag: " + strings.TrimSpace(spec))
+		}
+		tag := strings.TrimSpace(spec[:i])
+		if tag == "" {
+			return nil, errors.New("empty tag name")
+		}
+		if _, ok := tags[tag]; ok {
+			return nil, errors.New("duplicate tag: " + tag)
+		}
+		tags[tag] = strings.TrimSpace(spec[i+1:])
+	}
+	return tags, nil
+}
+
+// splitColon splits a colon separated list and trims the elements.
+func splitColon(s string) []string {
+	var l []string
+	for _, e := range strings.Split(s, ":") {
+		if e = strings.TrimSpace(e); e != "" {
+			l = append(l, e)
+		}
+	}
+	return l
+}
@@ -0,0 +1,146 @@
+package unbound
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseDMARC(t *testing.T) {
+	d, err := parseDMARC("v=DMARC1; p=Reject; rua=mailto:a@example.com, mailto:b@example.com; pct=50; aspf=s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Policy != "reject" || d.SubdomainPolicy != "reject" || d.Percent != 50 || d.ADKIM != "r" || d.ASPF != "s" {
+		t.Errorf("unexpected record: %+v", d)
+	}
+	if len(d.RUA) != 2 || d.RUA[1] != "mailto:b@example.com" {
+		t.Errorf("unexpected rua: %v", d.RUA)
+	}
+
+	d, err = parseDMARC("v=DMARC1; p=bogus; rua=mailto:a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Policy != "none" {
+		t.Errorf("expected policy none, got %s", d.Policy)
+	}
+
+	for _, s := range []string{
+		"v=DMARC1; p=bogus",
+		"v=DMARC1; p=none; pct=101",
+		"v=DMARC1; p=none; adkim=x",
+		"v=DMARC1; p=none; p=reject",
+		"v=DMARC2; p=none",
+		"v=DMARC1; p",
+	} {
+		if _, err := parseDMARC(s); err == nil {
+			t.Errorf("expected error parsing %q", s)
+		}
+	}
+}
+
+func TestParseDKIMKey(t *testing.T) {
+	// Example key from RFC 8463 appendix A.
+	k, err := parseDKIMKey("v=DKIM1; k=ed25519; p=11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := k.PublicKey.(ed25519.PublicKey); !ok || k.KeyType != "ed25519" {
+		t.Errorf("expected ed25519 key, got %T", k.PublicKey)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	p := base64.StdEncoding.EncodeToString(der)
+	k, err = parseDKIMKey("v=DKIM1; h=sha256; t=y:s; p=" + p[:40] + " " + p[40:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub, ok := k.PublicKey.(*rsa.PublicKey); !ok || !pub.Equal(&key.PublicKey) {
+		t.Errorf("expected RSA key, got %T", k.PublicKey)
+	}
+	if len(k.Flags) != 2 || k.HashAlgs[0] != "sha256" || k.Services[0] != "*" {
+		t.Errorf("unexpected key: %+v", k)
+	}
+
+	k, err = parseDKIMKey("v=DKIM1; p=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k.Revoked() {
+		t.Error("expected revoked key")
+	}
+
+	for _, s := range []string{
+		"v=DKIM1; k=rsa",
+		"v=DKIM1; k=dsa; p=" + p,
+		"v=DKIM1; k=ed25519; p=" + p,
+		"v=DKIM1; p=!!!",
+	} {
+		if _, err := parseDKIMKey(s); err == nil {
+			t.Errorf("expected error parsing %q", s)
+		}
+	}
+}
+
+func TestLookupDMARC(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+	for _, zone := range []string{"example.com.", "example.org."} {
+		if err := u.ZoneAdd(zone, "static"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := u.ZoneAdd("refused.example.", "refuse"); err != nil {
+		t.Fatal(err)
+	}
+	for _, rr := range []string{
+		`_dmarc.example.com. TXT "v=DMARC1; p=quarantine; sp=reject"`,
+		`_dmarc.example.com. TXT "not dmarc"`,
+		`_dmarc.sub.example.com. TXT "v=DMARC1; p=none"`,
+	} {
+		if err := u.DataAdd(rr); err != nil {
+			t.Fatalf("failed to add local data: %s", err)
+		}
+	}
+	if _, err := u.Resolve("_dmarc.example.com.", dns.TypeTXT, dns.ClassINET); err != nil {
+		t.Skipf("can not resolve local data: %s", err)
+	}
+
+	tests := []struct {
+		domain, found, policy string
+	}{
+		{"example.com", "example.com", "quarantine"},
+		{"sub.example.com.", "sub.example.com", "none"},
+		{"mail.example.com", "example.com", "quarantine"},
+	}
+	for _, tc := range tests {
+		d, err := u.LookupDMARC(tc.domain)
+		if err != nil {
+			t.Errorf("%s: %s", tc.domain, err)
+			continue
+		}
+		if d == nil || d.Domain != tc.found || d.Policy != tc.policy {
+			t.Errorf("%s: expected policy %s at %s, got %+v", tc.domain, tc.policy, tc.found, d)
+		}
+	}
+	if d, err := u.LookupDMARC("example.org"); d != nil || err != nil {
+		t.Errorf("expected no record for example.org, got %+v, %v", d, err)
+	}
+	// A failed lookup is an error, not a missing record.
+	if d, err := u.LookupDMARC("refused.example"); err == nil {
+		t.Errorf("expected error for failed lookup, got %+v", d)
+	}
+	if k, err := u.LookupDKIMKey("sel", "refused.example"); err == nil {
+		t.Errorf("expected error for failed lookup, got %+v", k)
+	}
+}
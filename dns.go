@@ -21,53 +21,84 @@ func (u *Unbound) DataRemoveRR(data dns.RR) error { return u.DataRemove(data.Str
 
 // Copied from the Go standard library
 
-// byPriorityWeight sorts SRV records by ascending priority and weight.
-type byPriorityWeight []*dns.SRV
+// rfc2782 orders records as specified in RFC 2782: by ascending priority, and
+// randomized by weight within a priority. The records are accessed by index,
+// so it can be used for all record types with a priority and a weight.
+type rfc2782 struct {
+	n        int
+	priority func(i int) uint16
+	weight   func(i int) uint16
+	swap     func(i, j int)
+}
 
-func (addrs byPriorityWeight) Len() int      { return len(addrs) }
-func (addrs byPriorityWeight) Swap(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] }
-func (addrs byPriorityWeight) Less(i, j int) bool {
-	return addrs[i].Priority < addrs[j].Priority ||
-		(addrs[i].Priority == addrs[j].Priority && addrs[i].Weight < addrs[j].Weight)
+func (r rfc2782) Len() int      { return r.n }
+func (r rfc2782) Swap(i, j int) { r.swap(i, j) }
+func (r rfc2782) Less(i, j int) bool {
+	return r.priority(i) < r.priority(j) ||
+		(r.priority(i) == r.priority(j) && r.weight(i) < r.weight(j))
 }
 
-// shuffleByWeight shuffles SRV records by weight using the algorithm
-// described in RFC 2782.
-func (addrs byPriorityWeight) shuffleByWeight() {
+// shuffleByWeight shuffles the records lo up to hi by weight using the
+// algorithm described in RFC 2782.
+func (r rfc2782) shuffleByWeight(lo, hi int) {
 	sum := 0
-	for _, addr := range addrs {
-		sum += int(addr.Weight)
+	for i := lo; i < hi; i++ {
+		sum += int(r.weight(i))
 	}
-	for sum > 0 && len(addrs) > 1 {
+	for sum > 0 && hi-lo > 1 {
 		s := 0
 		n := rand.Intn(sum + 1)
-		for i := range addrs {
-			s += int(addrs[i].Weight)
+		for i := lo; i < hi; i++ {
+			s += int(r.weight(i))
 			if s >= n {
-				if i > 0 {
-					t := addrs[i]
-					copy(addrs[1:i+1], addrs[0:i])
-					addrs[0] = t
+				// Move record i to the front, keeping the order of the others.
+				for j := i; j > lo; j-- {
+					r.swap(j, j-1)
 				}
 				break
 			}
 		}
-		sum -= int(addrs[0].Weight)
-		addrs = addrs[1:]
+		sum -= int(r.weight(lo))
+		lo++
 	}
 }
 
-// sort reorders SRV records as specified in RFC 2782.
-func (addrs byPriorityWeight) sort() {
-	sort.Sort(addrs)
+// sort reorders the records.
+func (r rfc2782) sort() {
+	sort.Sort(r)
 	i := 0
-	for j := 1; j < len(addrs); j++ {
-		if addrs[i].Priority != addrs[j].Priority {
-			addrs[i:j].shuffleByWeight()
+	for j := 1; j < r.n; j++ {
+		if r.priority(i) != r.priority(j) {
+			r.shuffleByWeight(i, j)
 			i = j
 		}
 	}
-	addrs[i:].shuffleByWeight()
+	r.shuffleByWeight(i, r.n)
+}
+
+// byPriorityWeight orders SRV records as specified in RFC 2782.
+type byPriorityWeight []*dns.SRV
+
+func (addrs byPriorityWeight) sort() {
+	rfc2782{
+		n:        len(addrs),
+		priority: func(i int) uint16 { return addrs[i].Priority },
+		weight:   func(i int) uint16 { return addrs[i].Weight },
+		swap:     func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] },
+	}.sort()
+}
+
+// byURIPriorityWeight orders URI records as specified in RFC 7553, which uses
+// the ordering of RFC 2782.
+type byURIPriorityWeight []*dns.URI
+
+func (addrs byURIPriorityWeight) sort() {
+	rfc2782{
+		n:        len(addrs),
+		priority: func(i int) uint16 { return addrs[i].Priority },
+		weight:   func(i int) uint16 { return addrs[i].Weight },
+		swap:     func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] },
+	}.sort()
 }
 
 // byPref implements sort.Interface to sort MX records by preference
@@ -85,3 +116,30 @@ func (s byPref) sort() {
 	}
 	sort.Sort(s)
 }
+
+// byPriority sorts service bindings by ascending priority.
+type byPriority []ServiceBinding
+
+func (s byPriority) Len() int           { return len(s) }
+func (s byPriority) Less(i, j int) bool { return s[i].Priority < s[j].Priority }
+func (s byPriority) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// sort reorders service bindings as specified in RFC 9460, bindings with the
+// same priority are randomized.
+func (s byPriority) sort() {
+	for i := range s {
+		j := rand.Intn(i + 1)
+		s[i], s[j] = s[j], s[i]
+	}
+	sort.Stable(s)
+}
+
+// byOrderPref sorts NAPTR records by ascending order and preference.
+type byOrderPref []*dns.NAPTR
+
+func (s byOrderPref) Len() int      { return len(s) }
+func (s byOrderPref) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byOrderPref) Less(i, j int) bool {
+	return s[i].Order < s[j].Order ||
+		(s[i].Order == s[j].Order && s[i].Preference < s[j].Preference)
+}
@@ -0,0 +1,37 @@
+package unbound
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestByURIPriorityWeightSort(t *testing.T) {
+	uri := []*dns.URI{
+		{Priority: 20, Weight: 1, Target: "https://c.example/"},
+		{Priority: 10, Weight: 0, Target: "https://b.example/"},
+		{Priority: 10, Weight: 100, Target: "https://a.example/"},
+	}
+	byURIPriorityWeight(uri).sort()
+	if uri[2].Priority != 20 {
+		t.Fatalf("expected priority 20 last, got %d", uri[2].Priority)
+	}
+	if uri[0].Priority != 10 || uri[1].Priority != 10 {
+		t.Fatalf("expected priority 10 first, got %d and %d", uri[0].Priority, uri[1].Priority)
+	}
+}
+
+func TestByPriorityWeightSort(t *testing.T) {
+	srv := []*dns.SRV{
+		{Priority: 20, Weight: 1, Target: "c.example."},
+		{Priority: 10, Weight: 0, Target: "b.example."},
+		{Priority: 30, Weight: 5, Target: "d.example."},
+		{Priority: 10, Weight: 100, Target: "a.example."},
+	}
+	byPriorityWeight(srv).sort()
+	for i, p := range []uint16{10, 10, 20, 30} {
+		if srv[i].Priority != p {
+			t.Fatalf("expected priority %d at %d, got %d", p, i, srv[i].Priority)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package unbound
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// DNSBLStatus is the status of a name or address on a blocklist.
+type DNSBLStatus int
+
+// DNSBL statuses.
+const (
+	DNSBLNotListed DNSBLStatus = iota
+	DNSBLListed
+	DNSBLError // The list could not be queried, see the Err of the result
+)
+
+func (s DNSBLStatus) String() string {
+	switch s {
+	case DNSBLListed:
+		return "listed"
+	case DNSBLError:
+		return "error"
+	}
+	return "not listed"
+}
+
+// DNSBLZone is a DNS blocklist.
+type DNSBLZone struct {
+	Zone  string           // Zone of the list, e.g. "zen.spamhaus.org"
+	Codes map[uint8]string // Category for the last octet of a 127.0.0.x return code
+}
+
+// DNSBLResult is the outcome of querying a single blocklist.
+type DNSBLResult struct {
+	Zone       string
+	Status     DNSBLStatus
+	Codes      []net.IP // Return codes, the addresses in the answer
+	Categories []string // Categories of the return codes known to the zone's Codes
+	Reasons    []string // Text from the TXT records of the listing
+	Err        error
+}
+
+// DNSBL queries DNS blocklists, see RFC 5782. It queries IP addresses (DNSBL)
+// and domain names (RHSBL).
+type DNSBL struct {
+	Unbound *Unbound
+	Zones   []DNSBLZone
+}
+
+// NewDNSBL returns a DNSBL that queries zones with u.
+func NewDNSBL(u *Unbound, zones ...string) *DNSBL {
+	d := &DNSBL{Unbound: u}
+	for _, z := range zones {
+		d.Zones = append(d.Zones, DNSBLZone{Zone: z})
+	}
+	return d
+}
+
+// Lookup queries all zones for ip. The zones are queried concurrently, the
+// results are in the order of the zones. IPv4 addresses are reversed per
+// octet, IPv6 addresses per nibble, RFC 5782 section 2.4.
+func (d *DNSBL) Lookup(ip net.IP) ([]DNSBLResult, error) {
+	reverse, err := dns.ReverseAddr(ip.String())
+	if err != nil {
+		return nil, err
+	}
+	reverse = strings.TrimSuffix(reverse, "in-addr.arpa.")
+	reverse = strings.TrimSuffix(reverse, "ip6.arpa.")
+	return d.lookup(reverse), nil
+}
+
+// LookupDomain queries all zones for domain, as a right hand side blocklist.
+// The zones are queried concurrently, the results are in the order of the
+// zones.
+func (d *DNSBL) LookupDomain(domain string) ([]DNSBLResult, error) {
+	if _, ok := dns.IsDomainName(domain); !ok {
+		return nil, errors.New("unbound: invalid domain name: " + domain)
+	}
+	return d.lookup(dns.Fqdn(domain)), nil
+}
+
+// lookup queries all zones for prefix, which ends in a dot.
+func (d *DNSBL) lookup(prefix string) []DNSBLResult {
+	results := make([]DNSBLResult, len(d.Zones))
+	var wg sync.WaitGroup
+	for i := range d.Zones {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = d.query(&d.Zones[i], prefix+dns.Fqdn(d.Zones[i].Zone))
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// query queries a single zone for name.
+func (d *DNSBL) query(z *DNSBLZone, name string) DNSBLResult {
+	res := DNSBLResult{Zone: z.Zone}
+	fail := func(err error) DNSBLResult {
+		res.Status, res.Err = DNSBLError, err
+		return res
+	}
+	r, err := d.Unbound.Resolve(name, dns.TypeA, dns.ClassINET)
+	if err != nil {
+		return fail(err)
+	}
+	switch {
+	case r.Bogus:
+		return fail(fmt.Errorf("unbound: A records are bogus for %s: %s", name, r.WhyBogus))
+	case r.NxDomain:
+		return res
+	case r.Rcode != dns.RcodeSuccess:
+		return fail(fmt.Errorf("unbound: query for %s failed: %s", name, dns.RcodeToString[r.Rcode]))
+	}
+	for _, rr := range r.Rr {
+		if a, ok := rr.(*dns.A); ok {
+			res.Codes = append(res.Codes, a.A.To4())
+		}
+	}
+	if len(res.Codes) == 0 {
+		return res
+	}
+	for _, ip := range res.Codes {
+		if ip[0] != 127 {
+			return fail(fmt.Errorf("unbound: %s returned %s, which is not a return code", z.Zone, ip))
+		}
+		// Lists use 127.255.255.0/24 to signal errors, such as refusing
+		// queries from public resolvers.
+		if ip[1] == 255 && ip[2] == 255 {
+			return fail(fmt.Errorf("unbound: %s returned error code %s", z.Zone, ip))
+		}
+		if ip[1] == 0 && ip[2] == 0 {
+			if c, ok := z.Codes[ip[3]]; ok {
+				res.Categories = append(res.Categories, c)
+			}
+		}
+	}
+	res.Status = DNSBLListed
+
+	if _, txt, err := d.Unbound.lookupTXT(name); err == nil {
+		for _, rr := range txt {
+			res.Reasons = append(res.Reasons, strings.Join(rr.Txt, ""))
+		}
+	}
+	return res
+}
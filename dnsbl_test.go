@@ -0,0 +1,68 @@
+package unbound
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestDNSBL(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+	// Static zones answer from local data only, names not in it are NXDOMAIN.
+	for _, zone := range []string{"bl.example.", "refuse.example."} {
+		if err := u.ZoneAdd(zone, "static"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, rr := range []string{
+		`2.0.0.127.bl.example. A 127.0.0.2`,
+		`2.0.0.127.bl.example. A 127.0.0.4`,
+		`2.0.0.127.bl.example. TXT "listed for spam"`,
+		`2.0.0.127.refuse.example. A 127.255.255.254`,
+		`1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.bl.example. A 127.0.0.2`,
+		`spam.example.bl.example. A 127.0.0.2`,
+		`bl.example. SOA ns.bl.example. hostmaster.bl.example. 1 3600 600 86400 300`,
+	} {
+		if err := u.DataAdd(rr); err != nil {
+			t.Fatalf("failed to add local data: %s", err)
+		}
+	}
+	if _, err := u.Resolve("2.0.0.127.bl.example.", dns.TypeA, dns.ClassINET); err != nil {
+		t.Skipf("can not resolve local data: %s", err)
+	}
+
+	d := NewDNSBL(u, "bl.example", "refuse.example")
+	d.Zones[0].Codes = map[uint8]string{2: "spam", 3: "exploits"}
+
+	res, err := d.Lookup(net.ParseIP("127.0.0.2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(res))
+	}
+	if r := res[0]; r.Status != DNSBLListed || len(r.Codes) != 2 || len(r.Categories) != 1 || r.Categories[0] != "spam" {
+		t.Errorf("unexpected result: %+v", r)
+	}
+	if r := res[0]; len(r.Reasons) != 1 || r.Reasons[0] != "listed for spam" {
+		t.Errorf("unexpected reasons: %v", r.Reasons)
+	}
+	if r := res[1]; r.Status != DNSBLError || r.Err == nil {
+		t.Errorf("expected error for refused query, got %+v", r)
+	}
+
+	res, _ = d.Lookup(net.ParseIP("2001:db8::1"))
+	if res[0].Status != DNSBLListed {
+		t.Errorf("expected IPv6 address to be listed, got %+v", res[0])
+	}
+	res, _ = d.Lookup(net.ParseIP("192.0.2.1"))
+	if res[0].Status != DNSBLNotListed {
+		t.Errorf("expected address not to be listed, got %+v", res[0])
+	}
+	res, _ = d.LookupDomain("spam.example")
+	if res[0].Status != DNSBLListed {
+		t.Errorf("expected domain to be listed, got %+v", res[0])
+	}
+}
@@ -0,0 +1,107 @@
+package unbound
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ErrInsecure is returned together with the records of an answer that is not
+// DNSSEC secure.
+var ErrInsecure = errors.New("unbound: answer is not secure")
+
+// SMIMEACert is an SMIMEA record, see RFC 8162.
+type SMIMEACert struct {
+	RR   *dns.SMIMEA
+	Cert *x509.Certificate // Certificate from the record, only set for selector 0 and matching type 0
+}
+
+// LookupOPENPGPKEY returns the OpenPGP transferable public keys for email, see
+// RFC 7929. The keys are returned in binary form. If the answer is not secure
+// the keys are returned with ErrInsecure, so callers checking the error
+// reject them.
+func (u *Unbound) LookupOPENPGPKEY(email string) (keys [][]byte, err error) {
+	r, err := u.lookupEmail(email, "_openpgpkey", dns.TypeOPENPGPKEY)
+	if err != nil {
+		return nil, err
+	}
+	for _, rr := range r.Rr {
+		k, err := base64.StdEncoding.DecodeString(rr.(*dns.OPENPGPKEY).PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("unbound: malformed OPENPGPKEY record for %s: %s", email, err)
+		}
+		keys = append(keys, k)
+	}
+	if !r.Secure {
+		return keys, ErrInsecure
+	}
+	return keys, nil
+}
+
+// LookupSMIMEA returns the SMIMEA records for email, see RFC 8162. Records
+// holding a full certificate have it parsed. If the answer is not secure the
+// records are returned with ErrInsecure, so callers checking the error
+// reject them.
+func (u *Unbound) LookupSMIMEA(email string) (smimea []SMIMEACert, err error) {
+	r, err := u.lookupEmail(email, "_smimecert", dns.TypeSMIMEA)
+	if err != nil {
+		return nil, err
+	}
+	for _, rr := range r.Rr {
+		x := rr.(*dns.SMIMEA)
+		c := SMIMEACert{RR: x}
+		if x.Selector == 0 && x.MatchingType == 0 {
+			der, err := hex.DecodeString(x.Certificate)
+			if err == nil {
+				c.Cert, err = x509.ParseCertificate(der)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("unbound: malformed SMIMEA certificate for %s: %s", email, err)
+			}
+		}
+		smimea = append(smimea, c)
+	}
+	if !r.Secure {
+		return smimea, ErrInsecure
+	}
+	return smimea, nil
+}
+
+// lookupEmail resolves the records of type qtype for email under label. A
+// bogus answer is an error.
+func (u *Unbound) lookupEmail(email, label string, qtype uint16) (*Result, error) {
+	name, err := emailName(email, label)
+	if err != nil {
+		return nil, err
+	}
+	r, err := u.Resolve(name, qtype, dns.ClassINET)
+	if err != nil {
+		return nil, err
+	}
+	if r.Bogus {
+		return nil, fmt.Errorf("unbound: %s records are bogus for %s: %s", dns.TypeToString[qtype], email, r.WhyBogus)
+	}
+	return r, nil
+}
+
+// emailName returns the owner name for email under label: the SHA2-256 hash
+// of the local part truncated to 28 octets, label and the domain, RFC 7929
+// section 3.
+func emailName(email, label string) (string, error) {
+	i := strings.LastIndexByte(email, '@')
+	if i <= 0 || i == len(email)-1 {
+		return "", errors.New("unbound: invalid email address: " + email)
+	}
+	local, domain := email[:i], email[i+1:]
+	if _, ok := dns.IsDomainName(domain); !ok {
+		return "", errors.New("unbound: invalid email address: " + email)
+	}
+	h := sha256.Sum256([]byte(local))
+	return hex.EncodeToString(h[:28]) + "." + label + "." + dns.Fqdn(domain), nil
+}
@@ -0,0 +1,19 @@
+package unbound
+
+import "testing"
+
+func TestEmailName(t *testing.T) {
+	// Example from RFC 7929, section 3.
+	name, err := emailName("hugh@example.com", "_openpgpkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x := "c93f1e400f26708f98cb19d936620da35eec8f72e57f9eec01c1afd6._openpgpkey.example.com."; name != x {
+		t.Errorf("expected %s, got %s", x, name)
+	}
+	for _, email := range []string{"example.com", "@example.com", "hugh@"} {
+		if _, err := emailName(email, "_smimecert"); err == nil {
+			t.Errorf("expected error for %q", email)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package unbound
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ZoneInfo describes the zone a name belongs to.
+type ZoneInfo struct {
+	Apex   string
+	SOA    *dns.SOA
+	NS     []*dns.NS
+	Secure bool // True if both the SOA and the NS answers were DNSSEC secure
+}
+
+// AuthServer is an authoritative name server of a zone.
+type AuthServer struct {
+	Name  string
+	Addrs []net.IP
+}
+
+// FindZone returns the zone name belongs to. It walks up the labels of name
+// until it finds a name with an SOA record, the apex of the zone. Names that
+// are aliases are never an apex.
+// This method is not found in Unbound.
+func (u *Unbound) FindZone(name string) (*ZoneInfo, error) {
+	name = dns.Fqdn(name)
+	if _, ok := dns.IsDomainName(name); !ok {
+		return nil, errors.New("unbound: invalid domain name: " + name)
+	}
+	for apex := name; ; {
+		r, err := u.Resolve(apex, dns.TypeSOA, dns.ClassINET)
+		if err != nil {
+			return nil, err
+		}
+		if r.Bogus {
+			return nil, fmt.Errorf("unbound: SOA record is bogus for %s: %s", apex, r.WhyBogus)
+		}
+		if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+			return nil, fmt.Errorf("unbound: SOA lookup for %s failed: %s", apex, dns.RcodeToString[r.Rcode])
+		}
+		if soa := zoneSOA(r, apex); soa != nil {
+			return u.zoneInfo(apex, soa, r.Secure)
+		}
+		if apex == "." {
+			return nil, errors.New("unbound: no zone found for: " + name)
+		}
+		i, _ := dns.NextLabel(apex, 0)
+		apex = apex[i:]
+		if apex == "" {
+			apex = "."
+		}
+	}
+}
+
+// zoneSOA returns the SOA record from r if apex, the name queried, is the apex
+// of a zone.
+func zoneSOA(r *Result, apex string) *dns.SOA {
+	if r.CanonName != "" && !strings.EqualFold(dns.Fqdn(r.CanonName), apex) {
+		return nil
+	}
+	for _, rr := range r.Rr {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa
+		}
+	}
+	return nil
+}
+
+// zoneInfo looks up the NS set of apex and returns the ZoneInfo.
+func (u *Unbound) zoneInfo(apex string, soa *dns.SOA, secure bool) (*ZoneInfo, error) {
+	r, err := u.Resolve(apex, dns.TypeNS, dns.ClassINET)
+	if err != nil {
+		return nil, err
+	}
+	if r.Bogus {
+		return nil, fmt.Errorf("unbound: NS records are bogus for %s: %s", apex, r.WhyBogus)
+	}
+	z := &ZoneInfo{Apex: apex, SOA: soa, Secure: secure && r.Secure}
+	for _, rr := range r.Rr {
+		if ns, ok := rr.(*dns.NS); ok {
+			z.NS = append(z.NS, ns)
+		}
+	}
+	return z, nil
+}
+
+// FindAuthoritativeServers returns the name servers of the zone name belongs
+// to, see FindZone, together with their addresses. Servers are in the order
+// of the NS set; a server whose addresses can not be found has no Addrs.
+// This method is not found in Unbound.
+func (u *Unbound) FindAuthoritativeServers(name string) ([]AuthServer, error) {
+	z, err := u.FindZone(name)
+	if err != nil {
+		return nil, err
+	}
+	servers := make([]AuthServer, 0, len(z.NS))
+	for _, ns := range z.NS {
+		addrs, _ := u.LookupIP(ns.Ns)
+		servers = append(servers, AuthServer{Name: ns.Ns, Addrs: addrs})
+	}
+	return servers, nil
+}
@@ -0,0 +1,68 @@
+package unbound
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestZoneSOA(t *testing.T) {
+	soa := &dns.SOA{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeSOA, Class: dns.ClassINET}}
+	r := &Result{Rr: []dns.RR{soa}, CanonName: "example.com."}
+	if zoneSOA(r, "example.com.") != soa {
+		t.Error("expected example.com. to be an apex")
+	}
+	r.CanonName = "alias.example.net."
+	if zoneSOA(r, "example.com.") != nil {
+		t.Error("expected alias not to be an apex")
+	}
+	if zoneSOA(&Result{}, "www.example.com.") != nil {
+		t.Error("expected name without SOA not to be an apex")
+	}
+}
+
+func TestFindZone(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+	if err := u.ZoneAdd("example.test.", "static"); err != nil {
+		t.Fatal(err)
+	}
+	for _, rr := range []string{
+		`example.test. SOA ns1.example.test. hostmaster.example.test. 1 3600 600 86400 300`,
+		`example.test. NS ns1.example.test.`,
+		`example.test. NS ns2.example.test.`,
+		`ns1.example.test. A 192.0.2.53`,
+		`www.example.test. A 192.0.2.80`,
+	} {
+		if err := u.DataAdd(rr); err != nil {
+			t.Fatalf("failed to add local data: %s", err)
+		}
+	}
+	if _, err := u.Resolve("example.test.", dns.TypeSOA, dns.ClassINET); err != nil {
+		t.Skipf("can not resolve local data: %s", err)
+	}
+
+	for _, name := range []string{"example.test", "www.example.test.", "_acme-challenge.www.example.test."} {
+		z, err := u.FindZone(name)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if z.Apex != "example.test." || z.SOA == nil || len(z.NS) != 2 {
+			t.Errorf("%s: unexpected zone: %+v", name, z)
+		}
+	}
+
+	servers, err := u.FindAuthoritativeServers("www.example.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) != 2 {
+		t.Fatalf("expected 2 servers, got %v", servers)
+	}
+	for _, s := range servers {
+		if s.Name == "ns1.example.test." && (len(s.Addrs) != 1 || s.Addrs[0].String() != "192.0.2.53") {
+			t.Errorf("unexpected addresses for %s: %v", s.Name, s.Addrs)
+		}
+	}
+}
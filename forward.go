@@ -0,0 +1,219 @@
+package unbound
+
+/*
+#include <stdlib.h>
+#include <unbound.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/miekg/dns"
+)
+
+// Zone types in the routing table.
+const (
+	ZoneStub    = "stub"
+	ZoneForward = "forward"
+)
+
+// Zone is an entry in the routing table of the context: queries for names
+// at or below Name are sent to Addrs instead of being resolved recursively.
+type Zone struct {
+	Name    string             // Zone name, fully qualified and lower case
+	Type    string             // ZoneStub or ZoneForward
+	Addrs   []string           // Server addresses in Unbound syntax, ip[@port][#authname]
+	Prime   bool               // Stub zone is primed, only for ZoneStub
+	Options ForwardZoneOptions // Only for ZoneForward
+}
+
+// ForwardZoneOptions are the options of a forward-zone clause.
+type ForwardZoneOptions struct {
+	First   bool // Resolve recursively when the forwarders fail (forward-first)
+	TLS     bool // Use TLS to the forwarders (forward-tls-upstream)
+	NoCache bool // Do not cache answers from this zone (forward-no-cache)
+}
+
+// SetStub wraps Unbound's ub_ctx_set_stub. Calling it again for the same zone
+// adds addr to the servers of the stub zone.
+func (u *Unbound) SetStub(zone, addr string, isPrime bool) error {
+	if err := checkZoneAddr(zone, addr); err != nil {
+		return err
+	}
+	prime := C.int(0)
+	if isPrime {
+		prime = 1
+	}
+	z := Zone{Name: zone, Type: ZoneStub, Addrs: []string{addr}, Prime: isPrime}
+	return u.route(z, true, func(ctx *C.struct_ub_ctx) C.int {
+		czone := C.CString(zone)
+		defer C.free(unsafe.Pointer(czone))
+		caddr := C.CString(addr)
+		defer C.free(unsafe.Pointer(caddr))
+		return C.ub_ctx_set_stub(ctx, czone, caddr, prime)
+	})
+}
+
+// SetFwdTLS forwards all queries over TLS to addr and port, 853 when port is
+// zero. The certificate of the forwarder must be valid for authName, see
+// SetTLSCertBundle for the certificates used to verify it. It adds a forward
+// zone for the root with forward-tls-upstream, see AddForwardZone, so unlike
+// SetTLS it does not move other forwarders and stub zones to TLS. It can not
+// be combined with SetFwd.
+// This method is not found in Unbound.
+func (u *Unbound) SetFwdTLS(addr string, port int, authName string) error {
+	if port == 0 {
+		port = 853
+	}
+	fwd := addr + "@" + strconv.Itoa(port)
+	if authName != "" {
+		fwd += "#" + authName
+	}
+	return u.AddForwardZone(".", []string{fwd}, ForwardZoneOptions{TLS: true})
+}
+
+// SetTLSCertBundle sets the file with the certificates used to verify TLS
+// connections to forwarders. It sets Unbound's tls-cert-bundle option.
+// This method is not found in Unbound.
+func (u *Unbound) SetTLSCertBundle(fname string) error {
+	return u.SetOption("tls-cert-bundle:", fname)
+}
+
+// AddForwardZone forwards queries for names at or below zone to addrs. The
+// addresses use Unbound's syntax: ip[@port][#authname]. Use SetFwd to
+// forward all other queries.
+//
+// The zone is added by loading a forward-zone clause with Config.
+// This method is not found in Unbound.
+func (u *Unbound) AddForwardZone(zone string, addrs []string, opts ForwardZoneOptions) error {
+	if len(addrs) == 0 {
+		return errors.New("unbound: no forward addresses for zone: " + zone)
+	}
+	for _, addr := range addrs {
+		if err := checkZoneAddr(zone, addr); err != nil {
+			return err
+		}
+	}
+	conf := forwardZoneConfig(dns.CanonicalName(zone), addrs, opts)
+	z := Zone{Name: zone, Type: ZoneForward, Addrs: addrs, Options: opts}
+	return u.route(z, false, func(ctx *C.struct_ub_ctx) C.int {
+		f, err := os.CreateTemp("", "unbound-forward-zone")
+		if err != nil {
+			return C.UB_READFILE
+		}
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(conf)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return C.UB_READFILE
+		}
+		cfname := C.CString(f.Name())
+		defer C.free(unsafe.Pointer(cfname))
+		return C.ub_ctx_config(ctx, cfname)
+	})
+}
+
+// Zones returns the routing table: the zones added with SetStub,
+// AddForwardZone and SetFwd, the latter as the forward zone for the root.
+// This method is not found in Unbound.
+func (u *Unbound) Zones() []Zone {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	zones := make([]Zone, len(u.zones))
+	for i, z := range u.zones {
+		z.Addrs = append([]string(nil), z.Addrs...)
+		zones[i] = z
+	}
+	return zones
+}
+
+// route calls f on the current context and adds z to the routing table
+// when it succeeds. If merge is true the addresses are added to an existing
+// zone of the same type and options, otherwise an existing zone is an error.
+func (u *Unbound) route(z Zone, merge bool, f ctxFunc) error {
+	z.Name = dns.CanonicalName(z.Name)
+	z.Addrs = append([]string(nil), z.Addrs...)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	i := 0
+	for ; i < len(u.zones); i++ {
+		if u.zones[i].Name == z.Name {
+			break
+		}
+	}
+	if i < len(u.zones) {
+		if old := u.zones[i]; !merge || old.Type != z.Type || old.Options != z.Options {
+			return fmt.Errorf("unbound: %s zone already exists for %s", old.Type, z.Name)
+		}
+	}
+	if err := u.applyLocked(f); err != nil {
+		return err
+	}
+	if i < len(u.zones) {
+		u.zones[i].Addrs = append(u.zones[i].Addrs, z.Addrs...)
+		u.zones[i].Prime = u.zones[i].Prime || z.Prime
+		return nil
+	}
+	u.zones = append(u.zones, z)
+	return nil
+}
+
+// checkZoneAddr checks zone and addr, which must be an IP address in Unbound's
+// ip[@port][#authname] syntax. Both end up in a config file, so whitespace
+// and quotes are rejected.
+func checkZoneAddr(zone, addr string) error {
+	if _, ok := dns.IsDomainName(zone); !ok || strings.ContainsAny(zone, "\" \t\r\n") {
+		return errors.New("unbound: invalid zone name: " + zone)
+	}
+	errAddr := errors.New("unbound: invalid server address: " + addr)
+	if strings.ContainsAny(addr, "\" \t\r\n") {
+		return errAddr
+	}
+	ip := addr
+	if i := strings.IndexByte(ip, '#'); i >= 0 {
+		if _, ok := dns.IsDomainName(ip[i+1:]); !ok {
+			return errAddr
+		}
+		ip = ip[:i]
+	}
+	if i := strings.IndexByte(ip, '@'); i >= 0 {
+		if _, err := strconv.ParseUint(ip[i+1:], 10, 16); err != nil {
+			return errAddr
+		}
+		ip = ip[:i]
+	}
+	if net.ParseIP(ip) == nil {
+		return errAddr
+	}
+	return nil
+}
+
+// forwardZoneConfig returns the forward-zone clause for zone.
+func forwardZoneConfig(zone string, addrs []string, opts ForwardZoneOptions) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "forward-zone:\n\tname: \"%s\"\n", zone)
+	for _, addr := range addrs {
+		fmt.Fprintf(&b, "\tforward-addr: %s\n", addr)
+	}
+	fmt.Fprintf(&b, "\tforward-first: %s\n", yesNo(opts.First))
+	fmt.Fprintf(&b, "\tforward-tls-upstream: %s\n", yesNo(opts.TLS))
+	fmt.Fprintf(&b, "\tforward-no-cache: %s\n", yesNo(opts.NoCache))
+	return b.String()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,192 @@
+package unbound
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestZones(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+
+	if err := u.SetFwd("192.0.2.1"); err != nil {
+		t.Fatalf("failed to set forwarder: %s", err)
+	}
+	if err := u.AddForwardZone("Corp.Example", []string{"10.0.0.1", "10.0.0.2@5353"}, ForwardZoneOptions{First: true}); err != nil {
+		t.Fatalf("failed to add forward zone: %s", err)
+	}
+	if err := u.AddForwardZone("corp.example.", []string{"10.0.0.3"}, ForwardZoneOptions{}); err == nil {
+		t.Fatal("expected error adding duplicate forward zone")
+	}
+	if err := u.AddForwardZone("lab.example.", []string{"not-an-address"}, ForwardZoneOptions{}); err == nil {
+		t.Fatal("expected error adding forward zone with invalid address")
+	}
+	if err := u.SetStub("10.in-addr.arpa.", "10.0.0.53", false); err != nil {
+		t.Fatalf("failed to set stub: %s", err)
+	}
+	if err := u.SetStub("10.in-addr.arpa.", "10.0.1.53", false); err != nil {
+		t.Fatalf("failed to add stub address: %s", err)
+	}
+
+	zones := u.Zones()
+	if len(zones) != 3 {
+		t.Fatalf("expected 3 zones, got %d", len(zones))
+	}
+	if z := zones[1]; z.Name != "corp.example." || z.Type != ZoneForward || len(z.Addrs) != 2 || !z.Options.First {
+		t.Errorf("unexpected forward zone: %+v", z)
+	}
+	if z := zones[2]; z.Type != ZoneStub || len(z.Addrs) != 2 {
+		t.Errorf("unexpected stub zone: %+v", z)
+	}
+}
+
+func TestSetFwdTLSZone(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+
+	if err := u.SetFwdTLS("not-an-address", 0, ""); err == nil {
+		t.Fatal("expected error for invalid address")
+	}
+	if zones := u.Zones(); len(zones) != 0 {
+		t.Fatalf("expected no zones after failed SetFwdTLS, got %+v", zones)
+	}
+	if err := u.SetFwdTLS("192.0.2.1", 0, "dns.example."); err != nil {
+		t.Fatal(err)
+	}
+	zones := u.Zones()
+	if len(zones) != 1 || zones[0].Name != "." || zones[0].Addrs[0] != "192.0.2.1@853#dns.example." || !zones[0].Options.TLS {
+		t.Errorf("unexpected zones: %+v", zones)
+	}
+	if err := u.SetFwd("192.0.2.2"); err == nil {
+		t.Error("expected error combining SetFwd with SetFwdTLS")
+	}
+}
+
+func TestForwardZoneConfig(t *testing.T) {
+	conf := forwardZoneConfig("corp.example.", []string{"10.0.0.1@853#dns.corp.example"}, ForwardZoneOptions{TLS: true})
+	for _, line := range []string{
+		"forward-zone:",
+		"\tname: \"corp.example.\"",
+		"\tforward-addr: 10.0.0.1@853#dns.corp.example",
+		"\tforward-first: no",
+		"\tforward-tls-upstream: yes",
+	} {
+		if !strings.Contains(conf, line+"\n") {
+			t.Errorf("expected %q in config:\n%s", line, conf)
+		}
+	}
+}
+
+func TestCheckZoneAddr(t *testing.T) {
+	tests := []struct {
+		zone, addr string
+		ok         bool
+	}{
+		{"corp.example.", "10.0.0.1", true},
+		{"corp.example.", "10.0.0.1@853#dns.corp.example", true},
+		{"corp.example.", "2001:db8::1@53", true},
+		{"corp.example.", "10.0.0.1@dns", false},
+		{"corp.example.", "10.0.0.1@70000", false},
+		{"corp.example.", "10.0.0.1#", false},
+		{"corp.example.", "10.0.0.1#x\nserver:\n\tdo-not-query-localhost: no", false},
+		{"corp.example.", "10.0.0.1#\"x\"", false},
+		{"corp\".example.", "10.0.0.1", false},
+		{"corp.example.\nserver:", "10.0.0.1", false},
+	}
+	for _, tc := range tests {
+		if err := checkZoneAddr(tc.zone, tc.addr); (err == nil) != tc.ok {
+			t.Errorf("checkZoneAddr(%q, %q): expected ok %t, got %v", tc.zone, tc.addr, tc.ok, err)
+		}
+	}
+}
+
+func TestFwdTLS(t *testing.T) {
+	if v := (&Unbound{}).Version(); v[0] == 1 && v[1] < 8 {
+		t.Skip("ub_ctx_set_tls needs Unbound 1.8.0 or later")
+	}
+	cert, bundle := newTestCert(t, "dns.example.")
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	s := &dns.Server{Listener: l, Net: "tcp-tls", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.RecursionAvailable = true
+		rr, _ := dns.NewRR(req.Question[0].Name + " 300 IN A 192.0.2.53")
+		m.Answer = append(m.Answer, rr)
+		w.WriteMsg(m)
+	})}
+	go s.ActivateAndServe()
+	defer s.Shutdown()
+
+	u := New()
+	defer u.Destroy()
+	if err := u.SetOption("do-not-query-localhost:", "no"); err != nil {
+		t.Fatalf("failed to set option: %s", err)
+	}
+	if err := u.SetTLSCertBundle(bundle); err != nil {
+		t.Fatalf("failed to set tls-cert-bundle: %s", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	if err := u.SetFwdTLS("127.0.0.1", port, "dns.example."); err != nil {
+		t.Fatalf("failed to set TLS forwarder: %s", err)
+	}
+	r, err := u.Resolve("www.example.", dns.TypeA, dns.ClassINET)
+	if err != nil {
+		t.Fatalf("failed to resolve over TLS: %s", err)
+	}
+	if len(r.Rr) != 1 || r.Rr[0].(*dns.A).A.String() != "192.0.2.53" {
+		t.Fatalf("unexpected answer from TLS forwarder: %v", r.Rr)
+	}
+	if zones := u.Zones(); len(zones) != 1 || zones[0].Addrs[0] != "127.0.0.1@"+strconv.Itoa(port)+"#dns.example." || !zones[0].Options.TLS {
+		t.Errorf("unexpected zones: %+v", zones)
+	}
+}
+
+// newTestCert returns a self-signed certificate for name and 127.0.0.1 and
+// the name of a file holding it in PEM format.
+func newTestCert(t *testing.T, name string) (tls.Certificate, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: strings.TrimSuffix(name, ".")},
+		DNSNames:              []string{strings.TrimSuffix(name, ".")},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle := filepath.Join(t.TempDir(), "bundle.pem")
+	if err := os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, bundle
+}
@@ -1,7 +1,9 @@
 package unbound
 
 import (
+	"errors"
 	"net"
+	"strconv"
 
 	"github.com/miekg/dns"
 )
@@ -10,7 +12,8 @@ import (
 // They are adapted to the package unbound and the package dns.
 
 // LookupAddr performs a reverse lookup for the given address, returning a
-// list of names mapping to that address.
+// list of names mapping to that address. The names are not verified, see
+// LookupAddrConfirmed.
 func (u *Unbound) LookupAddr(addr string) (name []string, err error) {
 	reverse, err := dns.ReverseAddr(addr)
 	if err != nil {
@@ -51,7 +54,8 @@ func (u *Unbound) LookupHost(host string) (addrs []string, err error) {
 
 // LookupIP looks up host using Unbound. It returns an array of
 // that host's IPv4 and IPv6 addresses.
-// The A and AAAA lookups are performed in parallel.
+// The A and AAAA lookups are performed in parallel. An error is only
+// returned when no addresses are found and one of the lookups failed.
 func (u *Unbound) LookupIP(host string) (addrs []net.IP, err error) {
 	c := make(chan *ResultError)
 	u.ResolveAsync(host, dns.TypeA, dns.ClassINET, c)
@@ -62,35 +66,52 @@ Wait:
 	for {
 		select {
 		case r := <-c:
-			for _, rr := range r.Rr {
-				if x, ok := rr.(*dns.A); ok {
-					addrs = append(addrs, x.A)
+			seen++
+			if r.Error != nil {
+				if err == nil {
+					err = r.Error
 				}
-				if x, ok := rr.(*dns.AAAA); ok {
-					addrs = append(addrs, x.AAAA)
+			} else {
+				for _, rr := range r.Rr {
+					if x, ok := rr.(*dns.A); ok {
+						addrs = append(addrs, x.A)
+					}
+					if x, ok := rr.(*dns.AAAA); ok {
+						addrs = append(addrs, x.AAAA)
+					}
 				}
 			}
-			seen++
 			if seen == 2 {
 				break Wait
 			}
 		}
 	}
-	return
+	if len(addrs) > 0 {
+		return addrs, nil
+	}
+	return nil, err
 }
 
 // LookupMX returns the DNS MX records for the given domain name sorted by
-// preference.
+// preference. Use LookupMailHosts to also handle domains without MX records
+// and Null MX records.
 func (u *Unbound) LookupMX(name string) (mx []*dns.MX, err error) {
+	_, mx, err = u.lookupMX(name)
+	return mx, err
+}
+
+// lookupMX is LookupMX, but also returns the Result.
+func (u *Unbound) lookupMX(name string) (*Result, []*dns.MX, error) {
 	r, err := u.Resolve(name, dns.TypeMX, dns.ClassINET)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	var mx []*dns.MX
 	for _, rr := range r.Rr {
 		mx = append(mx, rr.(*dns.MX))
 	}
 	byPref(mx).sort()
-	return
+	return r, mx, nil
 }
 
 // LookupNS returns the DNS NS records for the given domain name.
@@ -113,40 +134,138 @@ func (u *Unbound) LookupNS(name string) (ns []*dns.NS, err error) {
 // is, it looks up _service._proto.name. To accommodate services publishing
 // SRV records under non-standard names, if both service and proto are
 // empty strings, LookupSRV looks up name directly.
+//
+// The returned cname is the canonical name of the name looked up.
 func (u *Unbound) LookupSRV(service, proto, name string) (cname string, srv []*dns.SRV, err error) {
-	r := new(Result)
-	if service == "" && proto == "" {
-		r, err = u.Resolve(name, dns.TypeSRV, dns.ClassINET)
-	} else {
-		r, err = u.Resolve("_"+service+"._"+proto+"."+name, dns.TypeSRV, dns.ClassINET)
-	}
+	r, srv, err := u.lookupSRV(service, proto, name)
 	if err != nil {
 		return "", nil, err
 	}
+	// Unbound only sets the canonical name when it followed an alias.
+	if r.CanonName != "" {
+		return r.CanonName, srv, nil
+	}
+	return r.Qname, srv, nil
+}
+
+// LookupSRVAddrs looks up the SRV records like LookupSRV and resolves their
+// targets. It returns the addresses in host:port form, in the order of the
+// records as specified in RFC 2782. Addresses in the additional section of
+// the SRV answer are used when present, other targets are resolved with
+// LookupIP. Targets without addresses are skipped.
+// This method is not found in Unbound.
+func (u *Unbound) LookupSRVAddrs(service, proto, name string) (addrs []string, err error) {
+	r, srv, err := u.lookupSRV(service, proto, name)
+	if err != nil {
+		return nil, err
+	}
+	// A single target of "." means the service is not available, RFC 2782.
+	if len(srv) == 1 && srv[0].Target == "." {
+		return nil, errors.New("unbound: service not available at: " + r.Qname)
+	}
+	extra := make(map[string][]net.IP)
+	if r.AnswerPacket != nil {
+		for _, rr := range r.AnswerPacket.Extra {
+			owner := dns.CanonicalName(rr.Header().Name)
+			switch x := rr.(type) {
+			case *dns.A:
+				extra[owner] = append(extra[owner], x.A)
+			case *dns.AAAA:
+				extra[owner] = append(extra[owner], x.AAAA)
+			}
+		}
+	}
+	for _, rr := range srv {
+		ips, ok := extra[dns.CanonicalName(rr.Target)]
+		if !ok {
+			if ips, err = u.LookupIP(rr.Target); err != nil {
+				continue
+			}
+		}
+		port := strconv.Itoa(int(rr.Port))
+		for _, ip := range ips {
+			addrs = append(addrs, net.JoinHostPort(ip.String(), port))
+		}
+	}
+	return addrs, nil
+}
+
+// lookupSRV is LookupSRV, but also returns the Result.
+func (u *Unbound) lookupSRV(service, proto, name string) (*Result, []*dns.SRV, error) {
+	r, err := u.Resolve(serviceName(service, proto, name), dns.TypeSRV, dns.ClassINET)
+	if err != nil {
+		return nil, nil, err
+	}
+	var srv []*dns.SRV
 	for _, rr := range r.Rr {
 		srv = append(srv, rr.(*dns.SRV))
 	}
 	byPriorityWeight(srv).sort()
-	return "", srv, err
+	return r, srv, nil
+}
+
+// serviceName returns _service._proto.name, or name if both service and proto
+// are empty strings.
+func serviceName(service, proto, name string) string {
+	if service == "" && proto == "" {
+		return name
+	}
+	return "_" + service + "._" + proto + "." + name
+}
+
+// LookupURI tries to resolve an URI query of the given service, protocol,
+// and domain name. The returned records are sorted by priority and randomized
+// by weight within a priority.
+//
+// LookupURI constructs the DNS name to look up following RFC 7553. That
+// is, it looks up _service._proto.name. If both service and proto are
+// empty strings, LookupURI looks up name directly.
+func (u *Unbound) LookupURI(service, proto, name string) (uri []*dns.URI, err error) {
+	r, err := u.Resolve(serviceName(service, proto, name), dns.TypeURI, dns.ClassINET)
+	if err != nil {
+		return nil, err
+	}
+	for _, rr := range r.Rr {
+		uri = append(uri, rr.(*dns.URI))
+	}
+	byURIPriorityWeight(uri).sort()
+	return uri, nil
 }
 
 // LookupTXT returns the DNS TXT records for the given domain name.
 func (u *Unbound) LookupTXT(name string) (txt []string, err error) {
-	r, err := u.Resolve(name, dns.TypeTXT, dns.ClassINET)
+	_, records, err := u.lookupTXT(name)
 	if err != nil {
 		return nil, err
 	}
-	for _, rr := range r.Rr {
-		txt = append(txt, rr.(*dns.TXT).Txt...)
+	for _, rr := range records {
+		txt = append(txt, rr.Txt...)
 	}
 	return
 }
 
+// lookupTXT is LookupTXT, but returns the records, which keeps the strings
+// of a record together, and the Result.
+func (u *Unbound) lookupTXT(name string) (*Result, []*dns.TXT, error) {
+	r, err := u.Resolve(name, dns.TypeTXT, dns.ClassINET)
+	if err != nil {
+		return nil, nil, err
+	}
+	var txt []*dns.TXT
+	for _, rr := range r.Rr {
+		txt = append(txt, rr.(*dns.TXT))
+	}
+	return r, txt, nil
+}
+
 // LookupTLSA returns the DNS DANE records for the given domain service, protocol
 // and domainname.
 //
 // LookupTLSA constructs the DNS name to look up following RFC 6698. That
 // is, it looks up _port._proto.name.
+//
+// The records are returned regardless of their DNSSEC status, use
+// DANEVerifier to verify certificates with DANE.
 func (u *Unbound) LookupTLSA(service, proto, name string) (tlsa []*dns.TLSA, err error) {
 	tlsaname, err := dns.TLSAName(name, service, proto)
 	if err != nil {
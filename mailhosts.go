@@ -0,0 +1,93 @@
+package unbound
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// MXKind tells how the mail hosts of a domain were found.
+type MXKind int
+
+// Kinds of mail hosts.
+const (
+	MXExplicit MXKind = iota // The domain has MX records
+	MXImplicit               // The domain has no MX records, but has addresses, RFC 5321 section 5.1
+	MXNull                   // The domain has a Null MX record and does not accept mail, RFC 7505
+)
+
+func (k MXKind) String() string {
+	switch k {
+	case MXImplicit:
+		return "implicit"
+	case MXNull:
+		return "null"
+	}
+	return "explicit"
+}
+
+// MailHosts are the hosts accepting mail for a domain.
+type MailHosts struct {
+	Domain string
+	Kind   MXKind
+	MX     []*dns.MX // Sorted by preference; for MXImplicit a single record for Domain, empty for MXNull
+	Secure bool      // True if the MX answer was DNSSEC secure
+}
+
+// LookupMailHosts returns the hosts accepting mail for domain. If domain has
+// no MX records the domain itself is the mail host, provided it has an
+// address. If domain has a Null MX record, "0 .", it does not accept mail:
+// the Kind is MXNull and no hosts are returned. A domain that does not
+// exist, or has neither MX records nor addresses, is an error, as is a
+// failed MX lookup.
+// This method is not found in Unbound.
+func (u *Unbound) LookupMailHosts(domain string) (*MailHosts, error) {
+	domain = dns.Fqdn(domain)
+	r, mx, err := u.lookupMX(domain)
+	if err != nil {
+		return nil, err
+	}
+	if r.Bogus {
+		return nil, fmt.Errorf("unbound: MX records are bogus for %s: %s", domain, r.WhyBogus)
+	}
+	if r.NxDomain {
+		return nil, errors.New("unbound: no such domain: " + domain)
+	}
+	// Only a domain without MX records has an implicit MX, not a failed lookup.
+	if r.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("unbound: MX lookup for %s failed: %s", domain, dns.RcodeToString[r.Rcode])
+	}
+	m := &MailHosts{Domain: domain, Secure: r.Secure}
+	m.Kind, m.MX = classifyMX(mx)
+	if len(mx) > 0 {
+		return m, nil
+	}
+
+	ips, err := u.LookupIP(domain)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, errors.New("unbound: no mail hosts for: " + domain)
+	}
+	m.Kind = MXImplicit
+	m.MX = []*dns.MX{{Hdr: dns.RR_Header{Name: domain, Rrtype: dns.TypeMX, Class: dns.ClassINET}, Mx: domain}}
+	return m, nil
+}
+
+// classifyMX returns the kind of the MX records mx and the records that are
+// mail hosts. A Null MX among other records is a misconfiguration, RFC 7505
+// section 3; it is ignored.
+func classifyMX(mx []*dns.MX) (MXKind, []*dns.MX) {
+	if len(mx) == 1 && mx[0].Mx == "." {
+		return MXNull, nil
+	}
+	hosts := make([]*dns.MX, 0, len(mx))
+	for _, rr := range mx {
+		if rr.Mx != "." {
+			hosts = append(hosts, rr)
+		}
+	}
+	return MXExplicit, hosts
+}
@@ -0,0 +1,71 @@
+package unbound
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestClassifyMX(t *testing.T) {
+	mx := func(pref uint16, host string) *dns.MX { return &dns.MX{Preference: pref, Mx: host} }
+
+	if kind, hosts := classifyMX([]*dns.MX{mx(0, ".")}); kind != MXNull || len(hosts) != 0 {
+		t.Errorf("expected null MX, got %s %v", kind, hosts)
+	}
+	if kind, hosts := classifyMX([]*dns.MX{mx(0, "."), mx(10, "mail.example.com.")}); kind != MXExplicit || len(hosts) != 1 {
+		t.Errorf("expected one explicit MX, got %s %v", kind, hosts)
+	}
+	if kind, hosts := classifyMX([]*dns.MX{mx(10, "a.example.com."), mx(20, "b.example.com.")}); kind != MXExplicit || len(hosts) != 2 {
+		t.Errorf("expected two explicit MXs, got %s %v", kind, hosts)
+	}
+}
+
+func TestLookupMailHosts(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+	if err := u.ZoneAdd("refused.example.", "refuse"); err != nil {
+		t.Fatal(err)
+	}
+	for _, rr := range []string{
+		`explicit.example. MX 10 mail.explicit.example.`,
+		`implicit.example. A 192.0.2.1`,
+		`null.example. MX 0 .`,
+		`nomail.example. TXT "no mail here"`,
+	} {
+		if err := u.DataAdd(rr); err != nil {
+			t.Fatalf("failed to add local data: %s", err)
+		}
+	}
+	if _, err := u.Resolve("explicit.example.", dns.TypeMX, dns.ClassINET); err != nil {
+		t.Skipf("can not resolve local data: %s", err)
+	}
+
+	tests := []struct {
+		domain string
+		kind   MXKind
+		host   string
+	}{
+		{"explicit.example", MXExplicit, "mail.explicit.example."},
+		{"implicit.example", MXImplicit, "implicit.example."},
+		{"null.example", MXNull, ""},
+	}
+	for _, tc := range tests {
+		m, err := u.LookupMailHosts(tc.domain)
+		if err != nil {
+			t.Errorf("%s: %s", tc.domain, err)
+			continue
+		}
+		if m.Kind != tc.kind {
+			t.Errorf("%s: expected %s MX, got %s", tc.domain, tc.kind, m.Kind)
+		}
+		if tc.host == "" && len(m.MX) != 0 || tc.host != "" && (len(m.MX) != 1 || m.MX[0].Mx != tc.host) {
+			t.Errorf("%s: expected mail host %q, got %v", tc.domain, tc.host, m.MX)
+		}
+	}
+	if _, err := u.LookupMailHosts("nomail.example"); err == nil {
+		t.Error("expected error for domain without mail hosts")
+	}
+	if _, err := u.LookupMailHosts("refused.example"); err == nil {
+		t.Error("expected error for failed MX lookup")
+	}
+}
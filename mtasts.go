@@ -0,0 +1,296 @@
+package unbound
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// mtastsMaxAge is the largest max_age of an MTA-STS policy, RFC 8461 section 3.2.
+const mtastsMaxAge = 31557600 * time.Second
+
+// MTASTSPolicy is an MTA-STS policy, see RFC 8461.
+type MTASTSPolicy struct {
+	Domain  string
+	ID      string        // Policy id from the _mta-sts TXT record
+	Mode    string        // "enforce", "testing" or "none"
+	MX      []string      // MX host patterns, e.g. "*.example.net"
+	MaxAge  time.Duration // How long the policy may be cached
+	Expires time.Time     // When the cached policy expires
+	MXHosts []*dns.MX     // MX records of Domain that match the policy, set by LookupMTASTS
+}
+
+// Match returns true if host matches one of the MX patterns of the policy.
+// A wildcard pattern matches a single label only.
+func (p *MTASTSPolicy) Match(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, mx := range p.MX {
+		mx = strings.ToLower(strings.TrimSuffix(mx, "."))
+		if strings.HasPrefix(mx, "*.") {
+			i := strings.IndexByte(host, '.')
+			if i > 0 && host[i+1:] == mx[2:] {
+				return true
+			}
+			continue
+		}
+		if host == mx {
+			return true
+		}
+	}
+	return false
+}
+
+// MTASTSFetcher fetches the MTA-STS policy file of domain.
+type MTASTSFetcher func(domain string) ([]byte, error)
+
+// NewMTASTSFetcher returns an MTASTSFetcher that fetches the policy file from
+// https://mta-sts.<domain>/.well-known/mta-sts.txt with client. Redirects
+// are not followed, RFC 8461 section 3.3. If client is nil a client with a
+// timeout of one minute is used.
+func NewMTASTSFetcher(client *http.Client) MTASTSFetcher {
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+	c := *client
+	c.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	return func(domain string) ([]byte, error) {
+		resp, err := c.Get("https://mta-sts." + strings.TrimSuffix(domain, ".") + "/.well-known/mta-sts.txt")
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.New("unbound: MTA-STS policy fetch failed: " + resp.Status)
+		}
+		if t, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); t != "text/plain" {
+			return nil, errors.New("unbound: MTA-STS policy has wrong media type: " + t)
+		}
+		// RFC 8461 section 3.3 suggests limiting the size to 64 KiB.
+		return io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	}
+}
+
+// mtastsCache holds the MTA-STS policies fetched by LookupMTASTS.
+type mtastsCache struct {
+	mu       sync.Mutex
+	fetch    MTASTSFetcher
+	policies map[string]*MTASTSPolicy
+}
+
+// SetMTASTSFetcher sets the fetcher used by LookupMTASTS and empties the
+// policy cache. The default is NewMTASTSFetcher(nil).
+// This method is not found in Unbound.
+func (u *Unbound) SetMTASTSFetcher(f MTASTSFetcher) {
+	u.sts.mu.Lock()
+	defer u.sts.mu.Unlock()
+	u.sts.fetch = f
+	u.sts.policies = nil
+}
+
+// LookupMTASTS returns the MTA-STS policy of domain, see RFC 8461. The policy
+// id is read from the _mta-sts TXT record of domain, the policy itself is
+// fetched over HTTPS and cached until its max_age has passed or its id
+// changes. A cached policy is used when the TXT record is gone or fetching a
+// new policy fails. MXHosts holds the MX records of domain that match the
+// policy. If domain has no policy, the policy returned is nil.
+// This method is not found in Unbound.
+func (u *Unbound) LookupMTASTS(domain string) (*MTASTSPolicy, error) {
+	domain = strings.ToLower(dns.Fqdn(domain))
+	id, err := u.mtastsID(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	u.sts.mu.Lock()
+	cached := u.sts.policies[domain]
+	if cached != nil && time.Now().After(cached.Expires) {
+		delete(u.sts.policies, domain)
+		cached = nil
+	}
+	fetch := u.sts.fetch
+	u.sts.mu.Unlock()
+
+	p := cached
+	if id != "" && (cached == nil || cached.ID != id) {
+		if fetch == nil {
+			fetch = NewMTASTSFetcher(nil)
+		}
+		body, err := fetch(domain)
+		if err == nil {
+			p, err = parseMTASTS(body)
+		}
+		switch {
+		case err == nil:
+			p.Domain, p.ID = domain, id
+			p.Expires = time.Now().Add(p.MaxAge)
+			u.sts.mu.Lock()
+			if u.sts.policies == nil {
+				u.sts.policies = make(map[string]*MTASTSPolicy)
+			}
+			u.sts.policies[domain] = p
+			u.sts.mu.Unlock()
+		case cached == nil:
+			return nil, fmt.Errorf("unbound: MTA-STS policy for %s: %s", domain, err)
+		default:
+			p = cached
+		}
+	}
+	if p == nil {
+		return nil, nil
+	}
+
+	mx, err := u.LookupMX(domain)
+	if err != nil {
+		return nil, err
+	}
+	policy := *p
+	policy.MXHosts = nil
+	for _, rr := range mx {
+		if policy.Match(rr.Mx) {
+			policy.MXHosts = append(policy.MXHosts, rr)
+		}
+	}
+	return &policy, nil
+}
+
+// mtastsID returns the policy id from the _mta-sts TXT record of domain, or
+// the empty string if there is no valid record.
+func (u *Unbound) mtastsID(domain string) (string, error) {
+	r, txt, err := u.lookupTXT("_mta-sts." + domain)
+	if err != nil {
+		return "", err
+	}
+	if r.Bogus {
+		return "", fmt.Errorf("unbound: MTA-STS record is bogus for %s: %s", domain, r.WhyBogus)
+	}
+	var records []string
+	for _, rr := range txt {
+		if s := strings.Join(rr.Txt, ""); strings.HasPrefix(s, "v=STSv1") {
+			records = append(records, s)
+		}
+	}
+	// Multiple records must be treated as no record, RFC 8461 section 3.1.
+	if len(records) != 1 {
+		return "", nil
+	}
+	tags, err := parseTagList(records[0])
+	if err != nil || tags["v"] != "STSv1" {
+		return "", nil
+	}
+	id := tags["id"]
+	if len(id) == 0 || len(id) > 32 {
+		return "", nil
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return "", nil
+		}
+	}
+	return id, nil
+}
+
+// parseMTASTS parses an MTA-STS policy file, RFC 8461 section 3.2.
+func parseMTASTS(body []byte) (*MTASTSPolicy, error) {
+	p := new(MTASTSPolicy)
+	version := ""
+	haveMaxAge := false
+	s := bufio.NewScanner(bytes.NewReader(body))
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			return nil, errors.New("malformed line: " + line)
+		}
+		key, val := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		switch key {
+		case "version":
+			version = val
+		case "mode":
+			p.Mode = val
+		case "mx":
+			p.MX = append(p.MX, val)
+		case "max_age":
+			n, err := strconv.ParseUint(val, 10, 64)
+			if err != nil || len(val) > 10 {
+				return nil, errors.New("invalid max_age: " + val)
+			}
+			p.MaxAge = mtastsMaxAge
+			if n < uint64(mtastsMaxAge/time.Second) {
+				p.MaxAge = time.Duration(n) * time.Second
+			}
+			haveMaxAge = true
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if version != "STSv1" {
+		return nil, errors.New("version is not STSv1")
+	}
+	switch p.Mode {
+	case "enforce", "testing":
+		if len(p.MX) == 0 {
+			return nil, errors.New("no mx patterns")
+		}
+	case "none":
+	default:
+		return nil, errors.New("invalid mode: " + p.Mode)
+	}
+	if !haveMaxAge {
+		return nil, errors.New("missing max_age")
+	}
+	return p, nil
+}
+
+// LookupTLSRPT returns the reporting URIs from the _smtp._tls TXT record of
+// domain, see RFC 8460. If domain has no record, the URIs returned are nil.
+// This method is not found in Unbound.
+func (u *Unbound) LookupTLSRPT(domain string) (rua []string, err error) {
+	domain = dns.Fqdn(domain)
+	r, txt, err := u.lookupTXT("_smtp._tls." + domain)
+	if err != nil {
+		return nil, err
+	}
+	if r.Bogus {
+		return nil, fmt.Errorf("unbound: TLSRPT record is bogus for %s: %s", domain, r.WhyBogus)
+	}
+	var records []string
+	for _, rr := range txt {
+		if s := strings.Join(rr.Txt, ""); strings.HasPrefix(s, "v=TLSRPTv1") {
+			records = append(records, s)
+		}
+	}
+	switch len(records) {
+	case 0:
+		return nil, nil
+	case 1:
+	default:
+		return nil, errors.New("unbound: multiple TLSRPT records for: " + domain)
+	}
+	tags, err := parseTagList(records[0])
+	if err != nil {
+		return nil, fmt.Errorf("unbound: malformed TLSRPT record for %s: %s", domain, err)
+	}
+	for _, uri := range strings.Split(tags["rua"], ",") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			rua = append(rua, uri)
+		}
+	}
+	if len(rua) == 0 {
+		return nil, errors.New("unbound: TLSRPT record without rua for: " + domain)
+	}
+	return rua, nil
+}
@@ -0,0 +1,132 @@
+package unbound
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+const testMTASTSPolicy = "version: STSv1\r\nmode: enforce\r\nmx: mail.example.com\r\nmx: *.example.net\r\nmax_age: 86400\r\n"
+
+func TestParseMTASTS(t *testing.T) {
+	p, err := parseMTASTS([]byte(testMTASTSPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Mode != "enforce" || len(p.MX) != 2 || p.MaxAge.Seconds() != 86400 {
+		t.Errorf("unexpected policy: %+v", p)
+	}
+	for host, match := range map[string]bool{
+		"mail.example.com.":   true,
+		"MAIL.example.com":    true,
+		"mx1.example.net":     true,
+		"a.mx1.example.net":   false,
+		"example.net":         false,
+		"backup.example.com.": false,
+	} {
+		if p.Match(host) != match {
+			t.Errorf("expected match %t for %s", match, host)
+		}
+	}
+
+	if p, err := parseMTASTS([]byte("version: STSv1\nmode: none\nmax_age: 9999999999\n")); err != nil || p.MaxAge != mtastsMaxAge {
+		t.Errorf("expected max_age to be capped, got %+v, %v", p, err)
+	}
+	for _, s := range []string{
+		"version: STSv2\nmode: none\nmax_age: 1\n",
+		"version: STSv1\nmode: enforce\nmax_age: 1\n",
+		"version: STSv1\nmode: strict\nmx: a.example\nmax_age: 1\n",
+		"version: STSv1\nmode: none\n",
+		"version: STSv1\nmode: none\nmax_age: -1\n",
+		"version: STSv1\nmode: none\nmax_age: 99999999999\n",
+		"version STSv1\n",
+	} {
+		if _, err := parseMTASTS([]byte(s)); err == nil {
+			t.Errorf("expected error parsing %q", s)
+		}
+	}
+}
+
+func TestMTASTSFetcher(t *testing.T) {
+	mode := ""
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch mode {
+		case "redirect":
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+		case "html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(testMTASTSPolicy))
+		default:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(testMTASTSPolicy))
+		}
+	}))
+	defer srv.Close()
+
+	// The client of srv sends requests for *.example.com to srv.
+	fetch := NewMTASTSFetcher(srv.Client())
+	body, err := fetch("example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != testMTASTSPolicy {
+		t.Errorf("unexpected policy: %q", body)
+	}
+	mode = "redirect"
+	if _, err := fetch("example.com"); err == nil {
+		t.Error("expected error for redirect")
+	}
+	mode = "html"
+	if _, err := fetch("example.com"); err == nil {
+		t.Error("expected error for wrong media type")
+	}
+}
+
+func TestLookupMTASTS(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+	for _, rr := range []string{
+		`_mta-sts.example.com. TXT "v=STSv1; id=20160831085700Z;"`,
+		`example.com. MX 10 mail.example.com.`,
+		`example.com. MX 20 backup.example.org.`,
+		`_smtp._tls.example.com. TXT "v=TLSRPTv1; rua=mailto:tlsrpt@example.com,https://reports.example.com/v1"`,
+	} {
+		if err := u.DataAdd(rr); err != nil {
+			t.Fatalf("failed to add local data: %s", err)
+		}
+	}
+	if _, err := u.Resolve("_mta-sts.example.com.", dns.TypeTXT, dns.ClassINET); err != nil {
+		t.Skipf("can not resolve local data: %s", err)
+	}
+
+	fetches := 0
+	u.SetMTASTSFetcher(func(domain string) ([]byte, error) {
+		fetches++
+		return []byte(testMTASTSPolicy), nil
+	})
+	for i := 0; i < 2; i++ {
+		p, err := u.LookupMTASTS("example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p == nil || p.ID != "20160831085700Z" || len(p.MXHosts) != 1 || p.MXHosts[0].Mx != "mail.example.com." {
+			t.Errorf("unexpected policy: %+v", p)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("expected policy to be fetched once, got %d", fetches)
+	}
+	if p, err := u.LookupMTASTS("example.org"); p != nil || err != nil {
+		t.Errorf("expected no policy for example.org, got %+v, %v", p, err)
+	}
+
+	rua, err := u.LookupTLSRPT("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rua) != 2 || rua[0] != "mailto:tlsrpt@example.com" {
+		t.Errorf("unexpected rua: %v", rua)
+	}
+}
@@ -0,0 +1,216 @@
+package unbound
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// maxDDDSSteps is the number of non-terminal NAPTR rules followed before
+// giving up.
+const maxDDDSSteps = 16
+
+// LookupNAPTR returns the DNS NAPTR records for the given domain name sorted
+// by order and preference.
+func (u *Unbound) LookupNAPTR(name string) (naptr []*dns.NAPTR, err error) {
+	r, err := u.Resolve(name, dns.TypeNAPTR, dns.ClassINET)
+	if err != nil {
+		return nil, err
+	}
+	for _, rr := range r.Rr {
+		naptr = append(naptr, rr.(*dns.NAPTR))
+	}
+	sort.Stable(byOrderPref(naptr))
+	return
+}
+
+// DDDSResult is the outcome of a terminal NAPTR rule.
+type DDDSResult struct {
+	NAPTR  *dns.NAPTR // The terminal rule
+	Output string     // Result of the rule: a URI for flag "u", a domain name otherwise
+	SRV    []*dns.SRV // For flag "s", the SRV records of Output
+	Addrs  []net.IP   // For flag "a", the addresses of Output
+}
+
+// ResolveDDDS applies the NAPTR rules found at key to the application unique
+// string aus, see RFC 3403. Non-terminal rules are followed to the key they
+// produce. For the rules of the lowest order that match, terminal rules are
+// returned: rules with flag "s" are resolved to SRV records and rules with
+// flag "a" to addresses. Only rules for which match returns true are used,
+// match may be nil. No rules at key is not an error: the result is empty.
+// This method is not found in Unbound.
+func (u *Unbound) ResolveDDDS(aus, key string, match func(*dns.NAPTR) bool) ([]DDDSResult, error) {
+	for step := 0; step < maxDDDSSteps; step++ {
+		naptr, err := u.LookupNAPTR(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(naptr) == 0 {
+			if step == 0 {
+				return nil, nil
+			}
+			return nil, errors.New("unbound: non-terminal NAPTR rule leads to no rules at: " + key)
+		}
+
+		var (
+			result []DDDSResult
+			next   string
+			order  = -1
+		)
+		for _, rr := range naptr {
+			if order >= 0 && int(rr.Order) != order {
+				break
+			}
+			if match != nil && !match(rr) {
+				continue
+			}
+			out, ok, err := applyNAPTR(rr, aus)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			order = int(rr.Order)
+			flag := strings.ToLower(rr.Flags)
+			if flag == "" {
+				if next == "" {
+					next = out
+				}
+				continue
+			}
+			res := DDDSResult{NAPTR: rr, Output: out}
+			switch flag {
+			case "s":
+				if _, res.SRV, err = u.LookupSRV("", "", out); err != nil {
+					return nil, err
+				}
+			case "a":
+				if res.Addrs, err = u.LookupIP(out); err != nil {
+					return nil, err
+				}
+			}
+			result = append(result, res)
+		}
+		if len(result) > 0 || next == "" {
+			return result, nil
+		}
+		key = next
+	}
+	return nil, errors.New("unbound: too many non-terminal NAPTR rules for: " + aus)
+}
+
+// LookupENUM returns the ENUM records for the E.164 number e164, see RFC 6116.
+// The number may contain separators, e.g. "+1-555-123-4567". Only rules
+// with an E2U service are used.
+// This method is not found in Unbound.
+func (u *Unbound) LookupENUM(e164 string) ([]DDDSResult, error) {
+	aus, key, err := enumName(e164)
+	if err != nil {
+		return nil, err
+	}
+	return u.ResolveDDDS(aus, key, func(rr *dns.NAPTR) bool {
+		return strings.HasPrefix(strings.ToUpper(rr.Service), "E2U+")
+	})
+}
+
+// enumName returns the application unique string and the domain name under
+// e164.arpa for the E.164 number e164.
+func enumName(e164 string) (string, string, error) {
+	var digits []byte
+	for i := 0; i < len(e164); i++ {
+		switch c := e164[i]; {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == '+' && i == 0, c == '-', c == ' ', c == '.', c == '(', c == ')':
+		default:
+			return "", "", errors.New("unbound: invalid E.164 number: " + e164)
+		}
+	}
+	if len(digits) == 0 || !strings.HasPrefix(e164, "+") {
+		return "", "", errors.New("unbound: invalid E.164 number: " + e164)
+	}
+	labels := make([]string, len(digits))
+	for i, d := range digits {
+		labels[len(digits)-1-i] = string(d)
+	}
+	return "+" + string(digits), strings.Join(labels, ".") + ".e164.arpa.", nil
+}
+
+// applyNAPTR applies the rule rr to aus. It returns false if the regular
+// expression of rr does not match aus.
+func applyNAPTR(rr *dns.NAPTR, aus string) (string, bool, error) {
+	if rr.Regexp == "" {
+		return rr.Replacement, true, nil
+	}
+	re, repl, err := parseNAPTRRegexp(rr.Regexp)
+	if err != nil {
+		return "", false, fmt.Errorf("unbound: malformed NAPTR regexp %q: %s", rr.Regexp, err)
+	}
+	m := re.FindStringSubmatchIndex(aus)
+	if m == nil {
+		return "", false, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(repl); i++ {
+		c := repl[i]
+		if c != '\\' || i+1 == len(repl) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if d := repl[i]; d >= '0' && d <= '9' {
+			if n := int(d - '0'); 2*n+1 < len(m) && m[2*n] >= 0 {
+				b.WriteString(aus[m[2*n]:m[2*n+1]])
+			}
+			continue
+		}
+		b.WriteByte(repl[i])
+	}
+	return b.String(), true, nil
+}
+
+// parseNAPTRRegexp parses a substitution expression: a delimiter, an extended
+// regular expression, the delimiter, the replacement, the delimiter and the
+// optional flag "i".
+func parseNAPTRRegexp(s string) (*regexp.Regexp, string, error) {
+	if len(s) < 3 {
+		return nil, "", errors.New("too short")
+	}
+	delim := s[0]
+	if delim == '\\' || (delim >= '0' && delim <= '9') {
+		return nil, "", errors.New("invalid delimiter")
+	}
+	var parts []string
+	start := 1
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case delim:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	if len(parts) != 2 {
+		return nil, "", errors.New("expected three delimiters")
+	}
+	ere, repl, flags := parts[0], parts[1], s[start:]
+	switch flags {
+	case "":
+	case "i":
+		ere = "(?i)" + ere
+	default:
+		return nil, "", errors.New("unknown flags: " + flags)
+	}
+	re, err := regexp.Compile(ere)
+	if err != nil {
+		return nil, "", err
+	}
+	return re, repl, nil
+}
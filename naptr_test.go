@@ -0,0 +1,48 @@
+package unbound
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestApplyNAPTR(t *testing.T) {
+	tests := []struct {
+		regexp string
+		aus    string
+		out    string
+		ok     bool
+	}{
+		{`!^.*$!sip:info@example.com!`, "+441632960083", "sip:info@example.com", true},
+		{`!^\+44(.*)$!sip:\1@example.com!`, "+441632960083", "sip:1632960083@example.com", true},
+		{`!^\+31(.*)$!sip:\1@example.nl!`, "+441632960083", "", false},
+		{`/^HTTP$/http:\/\/www.example.com\//i`, "http", "http://www.example.com/", true},
+	}
+	for i, tc := range tests {
+		rr := &dns.NAPTR{Regexp: tc.regexp, Replacement: "."}
+		out, ok, err := applyNAPTR(rr, tc.aus)
+		if err != nil {
+			t.Errorf("test %d: %s", i, err)
+			continue
+		}
+		if ok != tc.ok || out != tc.out {
+			t.Errorf("test %d: expected %q %t, got %q %t", i, tc.out, tc.ok, out, ok)
+		}
+	}
+	if _, _, err := applyNAPTR(&dns.NAPTR{Regexp: "!^.*$!sip:x"}, "+1"); err == nil {
+		t.Error("expected error for regexp with two delimiters")
+	}
+}
+
+func TestEnumName(t *testing.T) {
+	aus, key, err := enumName("+44 1632-960083")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aus != "+441632960083" || key != "3.8.0.0.6.9.2.3.6.1.4.4.e164.arpa." {
+		t.Errorf("unexpected ENUM name: %s %s", aus, key)
+	}
+	if _, _, err := enumName("441632960083"); err == nil {
+		t.Error("expected error for number without +")
+	}
+}
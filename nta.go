@@ -0,0 +1,133 @@
+package unbound
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// NegativeTrustAnchor is a zone for which DNSSEC validation is disabled,
+// see RFC 7646.
+type NegativeTrustAnchor struct {
+	Zone   string    // Zone apex, fully qualified and lower case
+	Expiry time.Time // Time the anchor is removed, zero if it never expires
+}
+
+type negativeTrustAnchor struct {
+	NegativeTrustAnchor
+	timer *time.Timer
+}
+
+func (n *negativeTrustAnchor) stop() {
+	if n.timer != nil {
+		n.timer.Stop()
+	}
+}
+
+// AddNegativeTrustAnchor disables DNSSEC validation for zone and everything
+// below it until expiry. A zero expiry keeps the anchor until it is removed
+// with RemoveNegativeTrustAnchor. Adding an anchor for a zone that already has
+// one replaces its expiry.
+//
+// The anchor is implemented with Unbound's domain-insecure option. As a
+// context can not be changed once it has resolved names, a new context is
+// created with the current configuration and swapped in. This does not wait
+// for resolves in progress: they finish on the old context. The new context
+// starts with an empty cache, so adding an anchor, and its expiry, throw away
+// everything Unbound has cached.
+// This method is not found in Unbound.
+func (u *Unbound) AddNegativeTrustAnchor(zone string, expiry time.Time) error {
+	if _, ok := dns.IsDomainName(zone); !ok {
+		return errors.New("unbound: invalid zone name: " + zone)
+	}
+	if !expiry.IsZero() && !expiry.After(time.Now()) {
+		return errors.New("unbound: negative trust anchor already expired: " + zone)
+	}
+	zone = dns.CanonicalName(zone)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	old, ok := u.nta[zone]
+	n := &negativeTrustAnchor{NegativeTrustAnchor: NegativeTrustAnchor{Zone: zone, Expiry: expiry}}
+	u.nta[zone] = n
+	if err := u.rebuild(); err != nil {
+		if ok {
+			u.nta[zone] = old
+		} else {
+			delete(u.nta, zone)
+		}
+		return err
+	}
+	if ok {
+		old.stop()
+	}
+	if !expiry.IsZero() {
+		n.timer = time.AfterFunc(time.Until(expiry), func() { u.expireNegativeTrustAnchor(n) })
+	}
+	return nil
+}
+
+// RemoveNegativeTrustAnchor removes the negative trust anchor for zone,
+// enabling DNSSEC validation for it again. Like AddNegativeTrustAnchor it
+// swaps in a new context, which throws away the cache.
+// This method is not found in Unbound.
+func (u *Unbound) RemoveNegativeTrustAnchor(zone string) error {
+	zone = dns.CanonicalName(zone)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	n, ok := u.nta[zone]
+	if !ok {
+		return errors.New("unbound: no negative trust anchor for: " + zone)
+	}
+	delete(u.nta, zone)
+	if err := u.rebuild(); err != nil {
+		u.nta[zone] = n
+		return err
+	}
+	n.stop()
+	return nil
+}
+
+// NegativeTrustAnchors returns the active negative trust anchors sorted by
+// zone.
+// This method is not found in Unbound.
+func (u *Unbound) NegativeTrustAnchors() []NegativeTrustAnchor {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	nta := make([]NegativeTrustAnchor, 0, len(u.nta))
+	for _, n := range u.nta {
+		nta = append(nta, n.NegativeTrustAnchor)
+	}
+	sort.Slice(nta, func(i, j int) bool { return nta[i].Zone < nta[j].Zone })
+	return nta
+}
+
+// expireNegativeTrustAnchor removes n when its timer fires, unless it has
+// been replaced or removed in the meantime.
+func (u *Unbound) expireNegativeTrustAnchor(n *negativeTrustAnchor) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.nta[n.Zone] != n {
+		return
+	}
+	delete(u.nta, n.Zone)
+	if err := u.rebuild(); err != nil {
+		// The old context, which still has the anchor, stays in use. Retry
+		// shortly.
+		u.nta[n.Zone] = n
+		n.timer = time.AfterFunc(time.Second, func() { u.expireNegativeTrustAnchor(n) })
+	}
+}
+
+// ntaConfig returns the domain-insecure options for the negative trust
+// anchors.
+func (u *Unbound) ntaConfig() []ctxFunc {
+	conf := make([]ctxFunc, 0, len(u.nta))
+	for zone := range u.nta {
+		conf = append(conf, setOption("domain-insecure:", zone))
+	}
+	return conf
+}
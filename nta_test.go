@@ -0,0 +1,85 @@
+package unbound
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNegativeTrustAnchor(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+
+	if err := u.AddNegativeTrustAnchor("Example.org", time.Time{}); err != nil {
+		t.Fatalf("failed to add negative trust anchor: %s", err)
+	}
+	if err := u.AddNegativeTrustAnchor("example.net.", time.Now().Add(50*time.Millisecond)); err != nil {
+		t.Fatalf("failed to add negative trust anchor: %s", err)
+	}
+	nta := u.NegativeTrustAnchors()
+	if len(nta) != 2 || nta[0].Zone != "example.net." || nta[1].Zone != "example.org." {
+		t.Fatalf("expected example.net. and example.org., got %v", nta)
+	}
+
+	if err := u.RemoveNegativeTrustAnchor("example.org."); err != nil {
+		t.Fatalf("failed to remove negative trust anchor: %s", err)
+	}
+	if err := u.RemoveNegativeTrustAnchor("example.org."); err == nil {
+		t.Fatal("expected error removing absent negative trust anchor")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if nta := u.NegativeTrustAnchors(); len(nta) != 0 {
+		t.Fatalf("expected expired negative trust anchor to be removed, got %v", nta)
+	}
+}
+
+func TestNegativeTrustAnchorExpired(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+
+	if err := u.AddNegativeTrustAnchor("example.org.", time.Now().Add(-time.Minute)); err == nil {
+		t.Fatal("expected error adding expired negative trust anchor")
+	}
+}
+
+func TestNegativeTrustAnchorDestroyed(t *testing.T) {
+	u := New()
+	u.Destroy()
+
+	if err := u.AddNegativeTrustAnchor("example.org.", time.Time{}); err == nil {
+		t.Fatal("expected error adding negative trust anchor after Destroy")
+	}
+	if nta := u.NegativeTrustAnchors(); len(nta) != 0 {
+		t.Errorf("expected no negative trust anchors, got %+v", nta)
+	}
+}
+
+func TestRebuildInFlight(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+
+	// Pretend a resolve is in progress on the current context.
+	u.mu.RLock()
+	old := u.ctx
+	old.acquire()
+	u.mu.RUnlock()
+
+	done := make(chan error)
+	go func() { done <- u.AddNegativeTrustAnchor("example.org.", time.Time{}) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("rebuild waited for the resolve in progress")
+	}
+
+	old.mu.Lock()
+	retired, refs := old.retired, old.refs
+	old.mu.Unlock()
+	if !retired || refs != 1 || u.ctx == old {
+		t.Errorf("expected old context to be retired and in use, got retired %t with %d refs", retired, refs)
+	}
+	old.release()
+}
@@ -0,0 +1,197 @@
+package unbound
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// SMTPDANEPolicy is the TLS policy for an SMTP server, see RFC 7672.
+type SMTPDANEPolicy int
+
+const (
+	// SMTPDANENone means DANE does not apply: the MX or address records are
+	// not secure. TLS may be used opportunistically, without authentication.
+	SMTPDANENone SMTPDANEPolicy = iota
+	// SMTPDANEOpportunistic means the records are secure, but the server has
+	// no usable TLSA records. TLS may be used opportunistically, without
+	// authentication.
+	SMTPDANEOpportunistic
+	// SMTPDANERequired means the server has usable secure TLSA records. TLS
+	// is mandatory and the server must be authenticated with them.
+	SMTPDANERequired
+)
+
+func (p SMTPDANEPolicy) String() string {
+	switch p {
+	case SMTPDANEOpportunistic:
+		return "opportunistic"
+	case SMTPDANERequired:
+		return "dane-required"
+	}
+	return "none"
+}
+
+// SMTPDANEHost is an SMTP server for a destination domain and its DANE policy.
+type SMTPDANEHost struct {
+	Host       string         // Host name from the MX record, or the domain without MX records
+	Preference uint16         // Preference from the MX record
+	TLSAName   string         // Base domain the TLSA records were found at
+	Policy     SMTPDANEPolicy // TLS policy for the host
+	TLSA       []*dns.TLSA    // Usable TLSA records, only for SMTPDANERequired
+	Err        error          // When not nil, delivery to this host must be deferred
+
+	names []string // Reference identifiers for DANE-TA name checks
+}
+
+// LookupSMTPDANE returns the SMTP servers for domain with their DANE policy,
+// following RFC 7672 section 2. The MX records are resolved with LookupMX
+// semantics; when the domain has none, the domain itself is the server. A
+// domain with a Null MX record, "0 .", does not accept mail and is an error,
+// as are a domain that does not exist and a failed MX lookup.
+// If the MX records are not secure, all hosts get SMTPDANENone. For each host
+// the address records are resolved, expanding CNAMEs, and when these are
+// secure the TLSA records for _25._tcp are looked up at the expanded name and
+// then at the original host name. Only DANE-TA and DANE-EE records are usable
+// for SMTP.
+//
+// Lookup failures that must defer delivery, such as bogus TLSA records, are
+// reported in the Err field of the host.
+// This method is not found in Unbound.
+func (u *Unbound) LookupSMTPDANE(domain string) ([]SMTPDANEHost, error) {
+	domain = dns.Fqdn(domain)
+	r, mx, err := u.lookupMX(domain)
+	if err != nil {
+		return nil, err
+	}
+	if r.Bogus {
+		return nil, errors.New("unbound: MX records are bogus for: " + domain)
+	}
+	// A failed MX lookup must defer delivery, RFC 7672 section 2.2.1.
+	if r.Rcode == dns.RcodeNameError {
+		return nil, errors.New("unbound: no such domain: " + domain)
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("unbound: MX lookup for %s failed: %s", domain, dns.RcodeToString[r.Rcode])
+	}
+	if len(mx) == 0 {
+		mx = []*dns.MX{{Hdr: dns.RR_Header{Name: domain}, Mx: domain}}
+	}
+	kind, mx := classifyMX(mx)
+	if kind == MXNull {
+		return nil, errors.New("unbound: domain does not accept mail: " + domain)
+	}
+	if len(mx) == 0 {
+		return nil, errors.New("unbound: no mail hosts for: " + domain)
+	}
+
+	hosts := make([]SMTPDANEHost, 0, len(mx))
+	for _, m := range mx {
+		h := SMTPDANEHost{Host: m.Mx, Preference: m.Preference, names: []string{domain, m.Mx}}
+		if r.Secure {
+			u.smtpDANE(&h)
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// smtpDANE sets the policy of h, whose MX record is secure.
+func (u *Unbound) smtpDANE(h *SMTPDANEHost) {
+	secure, expanded, err := u.secureAddrs(h.Host)
+	if err != nil {
+		h.Err = err
+		return
+	}
+	if !secure {
+		return
+	}
+	h.Policy = SMTPDANEOpportunistic
+
+	bases := []string{h.Host}
+	if expanded != h.Host {
+		bases = []string{expanded, h.Host}
+		h.names = append(h.names, expanded)
+	}
+	for _, base := range bases {
+		tlsa, err := u.secureTLSA(base, "25", "tcp")
+		if err == ErrNoTLSA {
+			continue
+		}
+		if err != nil {
+			h.Policy = SMTPDANERequired
+			h.Err = err
+			return
+		}
+		var usable []*dns.TLSA
+		for _, rr := range tlsa {
+			// PKIX-TA and PKIX-EE are not usable, RFC 7672 section 3.1.3.
+			if rr.Usage == TLSADANETA || rr.Usage == TLSADANEEE {
+				usable = append(usable, rr)
+			}
+		}
+		if len(usable) == 0 {
+			continue
+		}
+		h.Policy = SMTPDANERequired
+		h.TLSAName = base
+		h.TLSA = usable
+		return
+	}
+}
+
+// secureAddrs resolves the addresses of host and returns whether all answers
+// are secure and the CNAME expanded name. Lookups that fail are an error.
+func (u *Unbound) secureAddrs(host string) (bool, string, error) {
+	secure, expanded := true, host
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		r, err := u.Resolve(host, qtype, dns.ClassINET)
+		if err != nil {
+			return false, "", err
+		}
+		if r.Bogus {
+			return false, "", fmt.Errorf("unbound: %s records are bogus for: %s", dns.TypeToString[qtype], host)
+		}
+		// A failed lookup must defer delivery, RFC 7672 section 2.2.2.
+		if r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError {
+			return false, "", fmt.Errorf("unbound: %s lookup for %s failed: %s", dns.TypeToString[qtype], host, dns.RcodeToString[r.Rcode])
+		}
+		secure = secure && r.Secure
+		if r.CanonName != "" {
+			expanded = dns.Fqdn(r.CanonName)
+		}
+	}
+	return secure, expanded, nil
+}
+
+// TLSConfig returns a tls.Config for connecting to h, e.g. with
+// smtp.Client.StartTLS. For SMTPDANERequired the certificate chain is
+// verified against the TLSA records, otherwise it is not verified at all as
+// the TLS is opportunistic.
+func (h SMTPDANEHost) TLSConfig() *tls.Config {
+	cfg := &tls.Config{ServerName: h.Host, InsecureSkipVerify: true}
+	if h.Policy == SMTPDANERequired {
+		cfg.VerifyConnection = h.VerifyConnection
+	}
+	return cfg
+}
+
+// VerifyConnection verifies the peer certificates of cs against the TLSA
+// records of h. DANE-TA certificates must be valid for the destination
+// domain, the MX host name or its CNAME expansion. It can be used as
+// tls.Config.VerifyConnection.
+func (h SMTPDANEHost) VerifyConnection(cs tls.ConnectionState) error {
+	if h.Err != nil {
+		return h.Err
+	}
+	if len(h.TLSA) == 0 {
+		return ErrNoTLSA
+	}
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("unbound: no certificates presented")
+	}
+	_, err := verifyTLSA(h.TLSA, cs.PeerCertificates, h.names, nil)
+	return err
+}
@@ -0,0 +1,79 @@
+package unbound
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestSMTPDANEVerifyConnection(t *testing.T) {
+	certs := newTestChain(t, "mx.example")
+	h := SMTPDANEHost{
+		Host:   "mx.example.",
+		Policy: SMTPDANERequired,
+		TLSA:   []*dns.TLSA{newTestTLSA(t, TLSADANETA, TLSASPKI, TLSASHA2_256, certs[1])},
+		names:  []string{"example.", "mx.example."},
+	}
+	cfg := h.TLSConfig()
+	if cfg.VerifyConnection == nil || !cfg.InsecureSkipVerify {
+		t.Fatal("expected DANE verification for dane-required host")
+	}
+	if err := cfg.VerifyConnection(tls.ConnectionState{PeerCertificates: certs}); err != nil {
+		t.Errorf("expected certificate chain to verify, got %s", err)
+	}
+
+	h.names = []string{"example.", "other.example."}
+	if err := h.VerifyConnection(tls.ConnectionState{PeerCertificates: certs}); err == nil {
+		t.Error("expected DANE-TA name check to fail")
+	}
+
+	h.Policy = SMTPDANEOpportunistic
+	if cfg := h.TLSConfig(); cfg.VerifyConnection != nil {
+		t.Error("expected no verification for opportunistic host")
+	}
+	if s := SMTPDANERequired.String(); s != "dane-required" {
+		t.Errorf("expected dane-required, got %s", s)
+	}
+}
+
+func TestLookupSMTPDANENullMX(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+	if err := u.ZoneAdd("refused.example.", "refuse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.ZoneAdd("static.example.", "static"); err != nil {
+		t.Fatal(err)
+	}
+	for _, rr := range []string{
+		`null.example. MX 0 .`,
+		`mixed.example. MX 0 .`,
+		`mixed.example. MX 10 mail.mixed.example.`,
+	} {
+		if err := u.DataAdd(rr); err != nil {
+			t.Fatalf("failed to add local data: %s", err)
+		}
+	}
+	if _, err := u.Resolve("null.example.", dns.TypeMX, dns.ClassINET); err != nil {
+		t.Skipf("can not resolve local data: %s", err)
+	}
+
+	if hosts, err := u.LookupSMTPDANE("null.example"); err == nil {
+		t.Errorf("expected error for Null MX, got %v", hosts)
+	}
+	hosts, err := u.LookupSMTPDANE("mixed.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0].Host != "mail.mixed.example." {
+		t.Errorf("expected only mail.mixed.example., got %v", hosts)
+	}
+	// A failed MX lookup or a domain that does not exist must not fall back
+	// to the domain as its own mail server.
+	for _, domain := range []string{"refused.example", "nxdomain.static.example"} {
+		if hosts, err := u.LookupSMTPDANE(domain); err == nil {
+			t.Errorf("%s: expected error, got %v", domain, hosts)
+		}
+	}
+}
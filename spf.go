@@ -0,0 +1,630 @@
+package unbound
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// SPFResult is the result of an SPF check, see RFC 7208 section 2.6.
+type SPFResult int
+
+// SPF results.
+const (
+	SPFNone SPFResult = iota
+	SPFNeutral
+	SPFPass
+	SPFFail
+	SPFSoftFail
+	SPFTempError
+	SPFPermError
+)
+
+func (r SPFResult) String() string {
+	switch r {
+	case SPFNeutral:
+		return "neutral"
+	case SPFPass:
+		return "pass"
+	case SPFFail:
+		return "fail"
+	case SPFSoftFail:
+		return "softfail"
+	case SPFTempError:
+		return "temperror"
+	case SPFPermError:
+		return "permerror"
+	}
+	return "none"
+}
+
+// Limits from RFC 7208 section 4.6.4.
+const (
+	spfMaxLookups     = 10 // Mechanisms and modifiers that do DNS lookups
+	spfMaxVoidLookups = 2  // Lookups that return no records
+	spfMaxNames       = 10 // MX or PTR names looked at by a single mechanism
+)
+
+// CheckSPF evaluates the SPF policy of sender for a message coming from ip,
+// see RFC 7208. The policy is fetched with LookupTXT semantics. If sender is
+// empty, the HELO identity helo is checked. For SPFTempError and SPFPermError
+// the error tells what went wrong. Explanations (the exp modifier) are not
+// evaluated, and the %{p} macro expands to "unknown".
+// This method is not found in Unbound.
+func (u *Unbound) CheckSPF(ip net.IP, helo, sender string) (SPFResult, error) {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if sender == "" {
+		sender = "postmaster@" + helo
+	}
+	local, domain := "postmaster", sender
+	if i := strings.LastIndexByte(sender, '@'); i >= 0 {
+		domain = sender[i+1:]
+		if i > 0 {
+			local = sender[:i]
+		}
+	}
+	c := &spfCheck{u: u, ip: ip, helo: helo, sender: local + "@" + domain, local: local, domain: dns.Fqdn(domain)}
+	res, err := c.checkHost(c.domain)
+	if res == SPFTempError || res == SPFPermError {
+		return res, err
+	}
+	return res, nil
+}
+
+// spfCheck is the state of a single SPF evaluation.
+type spfCheck struct {
+	u      *Unbound
+	ip     net.IP
+	helo   string
+	sender string
+	local  string
+	domain string
+
+	lookups int
+	voids   int
+}
+
+// spfError is an error that ends the evaluation with result.
+type spfError struct {
+	result SPFResult
+	err    string
+}
+
+func (e *spfError) Error() string { return "unbound: spf: " + e.err }
+
+func permError(format string, a ...interface{}) error {
+	return &spfError{SPFPermError, fmt.Sprintf(format, a...)}
+}
+
+func tempError(format string, a ...interface{}) error {
+	return &spfError{SPFTempError, fmt.Sprintf(format, a...)}
+}
+
+// spfTerm is a mechanism or modifier of an SPF record.
+type spfTerm struct {
+	qualifier byte   // For mechanisms, one of + - ~ ?
+	name      string // Lower case mechanism or modifier name
+	arg       string // Domain spec, address or modifier value
+	modifier  bool
+	cidr4     int // Prefix length for IPv4, -1 if not given
+	cidr6     int // Prefix length for IPv6, -1 if not given
+}
+
+// checkHost implements the check_host() function of RFC 7208 section 4.
+func (c *spfCheck) checkHost(domain string) (SPFResult, error) {
+	if !validSPFDomain(domain) {
+		return SPFNone, nil
+	}
+	record, err := c.record(domain)
+	if err != nil {
+		return errResult(err), err
+	}
+	if record == "" {
+		return SPFNone, nil
+	}
+	terms, err := parseSPF(record)
+	if err != nil {
+		return SPFPermError, err
+	}
+
+	var redirect *spfTerm
+	for i := range terms {
+		t := &terms[i]
+		if t.modifier {
+			if t.name == "redirect" {
+				redirect = t
+			}
+			continue
+		}
+		match, err := c.match(t, domain)
+		if err != nil {
+			return errResult(err), err
+		}
+		if match {
+			return qualifierResult(t.qualifier), nil
+		}
+	}
+
+	if redirect == nil {
+		return SPFNeutral, nil
+	}
+	if err := c.count(); err != nil {
+		return SPFPermError, err
+	}
+	target, err := c.target(redirect.arg, domain)
+	if err != nil {
+		return errResult(err), err
+	}
+	res, err := c.checkHost(target)
+	if res == SPFNone {
+		return SPFPermError, permError("redirect to %s without SPF record", target)
+	}
+	return res, err
+}
+
+// record returns the SPF record of domain, or the empty string if it has none.
+func (c *spfCheck) record(domain string) (string, error) {
+	r, txt, err := c.u.lookupTXT(domain)
+	if err != nil {
+		return "", tempError("TXT lookup for %s failed: %s", domain, err)
+	}
+	if r.Rcode == dns.RcodeNameError {
+		return "", nil
+	}
+	if r.Rcode != dns.RcodeSuccess || r.Bogus {
+		return "", tempError("TXT lookup for %s failed: %s", domain, dns.RcodeToString[r.Rcode])
+	}
+	record := ""
+	for _, rr := range txt {
+		s := strings.Join(rr.Txt, "")
+		if !strings.EqualFold(s, "v=spf1") && !strings.HasPrefix(strings.ToLower(s), "v=spf1 ") {
+			continue
+		}
+		if record != "" {
+			return "", permError("multiple SPF records for %s", domain)
+		}
+		record = s
+	}
+	return record, nil
+}
+
+// match evaluates the mechanism t for domain.
+func (c *spfCheck) match(t *spfTerm, domain string) (bool, error) {
+	switch t.name {
+	case "all":
+		return true, nil
+	case "ip4", "ip6":
+		_, n, err := net.ParseCIDR(t.arg)
+		return err == nil && n.Contains(c.ip) && (len(c.ip) == net.IPv4len) == (t.name == "ip4"), nil
+	}
+
+	if err := c.count(); err != nil {
+		return false, err
+	}
+	target, err := c.target(t.arg, domain)
+	if err != nil {
+		return false, err
+	}
+	switch t.name {
+	case "include":
+		res, err := c.checkHost(target)
+		switch res {
+		case SPFPass:
+			return true, nil
+		case SPFFail, SPFSoftFail, SPFNeutral:
+			return false, nil
+		case SPFNone:
+			return false, permError("include of %s without SPF record", target)
+		}
+		return false, err
+	case "a":
+		return c.matchAddrs(target, t)
+	case "mx":
+		mx, err := c.resolve(target, dns.TypeMX)
+		if err != nil {
+			return false, err
+		}
+		if len(mx) > spfMaxNames {
+			return false, permError("more than %d MX records for %s", spfMaxNames, target)
+		}
+		for _, rr := range mx {
+			match, err := c.matchAddrs(rr.(*dns.MX).Mx, t)
+			if match || err != nil {
+				return match, err
+			}
+		}
+		return false, nil
+	case "ptr":
+		for _, name := range c.validatedNames() {
+			if dns.IsSubDomain(target, name) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "exists":
+		a, err := c.resolve(target, dns.TypeA)
+		return len(a) > 0, err
+	}
+	return false, permError("unknown mechanism %s", t.name)
+}
+
+// matchAddrs returns true if one of the addresses of name, in the network
+// given by the prefix lengths of t, contains the IP address.
+func (c *spfCheck) matchAddrs(name string, t *spfTerm) (bool, error) {
+	qtype, bits, cidr := dns.TypeA, 32, t.cidr4
+	if len(c.ip) != net.IPv4len {
+		qtype, bits, cidr = dns.TypeAAAA, 128, t.cidr6
+	}
+	if cidr < 0 {
+		cidr = bits
+	}
+	mask := net.CIDRMask(cidr, bits)
+	rrs, err := c.resolve(name, qtype)
+	if err != nil {
+		return false, err
+	}
+	for _, rr := range rrs {
+		var ip net.IP
+		switch x := rr.(type) {
+		case *dns.A:
+			ip = x.A.To4()
+		case *dns.AAAA:
+			ip = x.AAAA
+		default:
+			continue
+		}
+		if ip.Mask(mask).Equal(c.ip.Mask(mask)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// validatedNames returns the names of the IP address that resolve back to
+// it, see RFC 7208 section 5.5. Lookup errors are ignored.
+func (c *spfCheck) validatedNames() []string {
+	reverse, err := dns.ReverseAddr(c.ip.String())
+	if err != nil {
+		return nil
+	}
+	ptr, err := c.resolve(reverse, dns.TypePTR)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for i, rr := range ptr {
+		if i == spfMaxNames {
+			break
+		}
+		name := rr.(*dns.PTR).Ptr
+		if ok, _ := c.matchAddrs(name, &spfTerm{cidr4: -1, cidr6: -1}); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// resolve returns the records of type qtype for name, counting void lookups.
+func (c *spfCheck) resolve(name string, qtype uint16) ([]dns.RR, error) {
+	r, err := c.u.Resolve(name, qtype, dns.ClassINET)
+	if err != nil {
+		return nil, tempError("%s lookup for %s failed: %s", dns.TypeToString[qtype], name, err)
+	}
+	if r.Bogus || (r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError) {
+		return nil, tempError("%s lookup for %s failed: %s", dns.TypeToString[qtype], name, dns.RcodeToString[r.Rcode])
+	}
+	if len(r.Rr) == 0 {
+		c.voids++
+		if c.voids > spfMaxVoidLookups {
+			return nil, permError("more than %d void lookups", spfMaxVoidLookups)
+		}
+	}
+	return r.Rr, nil
+}
+
+// count counts a mechanism or modifier that does DNS lookups.
+func (c *spfCheck) count() error {
+	c.lookups++
+	if c.lookups > spfMaxLookups {
+		return permError("more than %d DNS lookups", spfMaxLookups)
+	}
+	return nil
+}
+
+// target returns the expanded domain spec, or domain if spec is empty.
+func (c *spfCheck) target(spec, domain string) (string, error) {
+	if spec == "" {
+		return domain, nil
+	}
+	name, err := c.expand(spec, domain)
+	if err != nil {
+		return "", err
+	}
+	name = strings.TrimSuffix(name, ".")
+	// Remove labels from the left until the name fits, RFC 7208 section 7.3.
+	for len(name) > 253 {
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[i+1:]
+	}
+	return dns.Fqdn(name), nil
+}
+
+// expand expands the macros in s, see RFC 7208 section 7.
+func (c *spfCheck) expand(s, domain string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 == len(s) {
+			return "", permError("macro at end of %q", s)
+		}
+		i++
+		switch s[i] {
+		case '%':
+			b.WriteByte('%')
+			continue
+		case '_':
+			b.WriteByte(' ')
+			continue
+		case '-':
+			b.WriteString("%20")
+			continue
+		case '{':
+		default:
+			return "", permError("invalid macro in %q", s)
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 2 {
+			return "", permError("invalid macro in %q", s)
+		}
+		v, err := c.macro(s[i+1:i+j], domain)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(v)
+		i += j
+	}
+	return b.String(), nil
+}
+
+// macro returns the value of the macro with letter and transformers m.
+func (c *spfCheck) macro(m, domain string) (string, error) {
+	letter := m[0]
+	var v string
+	switch letter | 0x20 {
+	case 's':
+		v = c.sender
+	case 'l':
+		v = c.local
+	case 'o':
+		v = strings.TrimSuffix(c.domain, ".")
+	case 'd':
+		v = strings.TrimSuffix(domain, ".")
+	case 'i':
+		v = spfIP(c.ip)
+	case 'p':
+		v = "unknown"
+	case 'v':
+		v = "ip6"
+		if len(c.ip) == net.IPv4len {
+			v = "in-addr"
+		}
+	case 'h':
+		v = c.helo
+	default:
+		return "", permError("invalid macro letter %c", letter)
+	}
+
+	m = m[1:]
+	n := 0
+	for len(m) > 0 && m[0] >= '0' && m[0] <= '9' {
+		n = n*10 + int(m[0]-'0')
+		m = m[1:]
+	}
+	reverse := false
+	if len(m) > 0 && (m[0] == 'r' || m[0] == 'R') {
+		reverse = true
+		m = m[1:]
+	}
+	delims := "."
+	if m != "" {
+		if strings.Trim(m, ".-+,/_=") != "" {
+			return "", permError("invalid macro delimiters %q", m)
+		}
+		delims = m
+	}
+
+	parts := strings.FieldsFunc(v, func(r rune) bool { return strings.ContainsRune(delims, r) })
+	if reverse {
+		for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+			parts[i], parts[j] = parts[j], parts[i]
+		}
+	}
+	if n > 0 && n < len(parts) {
+		parts = parts[len(parts)-n:]
+	}
+	v = strings.Join(parts, ".")
+	if letter >= 'A' && letter <= 'Z' {
+		v = spfEscape(v)
+	}
+	return v, nil
+}
+
+// spfEscape URL escapes s: all characters except the unreserved characters
+// of RFC 3986 are percent encoded.
+func spfEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-._~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// spfIP returns ip in the format of the %{i} macro: dotted quad for IPv4 and
+// dot separated nibbles for IPv6.
+func spfIP(ip net.IP) string {
+	if len(ip) == net.IPv4len {
+		return ip.String()
+	}
+	const hex = "0123456789abcdef"
+	nibbles := make([]string, 0, 32)
+	for _, b := range ip.To16() {
+		nibbles = append(nibbles, string(hex[b>>4]), string(hex[b&0xf]))
+	}
+	return strings.Join(nibbles, ".")
+}
+
+// parseSPF parses the terms of the SPF record s.
+func parseSPF(s string) ([]spfTerm, error) {
+	fields := strings.Fields(s)[1:] // skip v=spf1
+	terms := make([]spfTerm, 0, len(fields))
+	seen := map[string]bool{}
+	for _, f := range fields {
+		t := spfTerm{cidr4: -1, cidr6: -1}
+		if i := strings.IndexAny(f, "=:/"); i > 0 && f[i] == '=' {
+			t.modifier, t.name, t.arg = true, strings.ToLower(f[:i]), f[i+1:]
+			if t.name == "redirect" || t.name == "exp" {
+				if seen[t.name] {
+					return nil, permError("duplicate %s modifier", t.name)
+				}
+				seen[t.name] = true
+			}
+			terms = append(terms, t)
+			continue
+		}
+
+		t.qualifier = '+'
+		if strings.IndexByte("+-~?", f[0]) >= 0 {
+			t.qualifier, f = f[0], f[1:]
+		}
+		name, arg := f, ""
+		if i := strings.IndexAny(f, ":/"); i >= 0 {
+			name, arg = f[:i], f[i:]
+		}
+		t.name = strings.ToLower(name)
+		var err error
+		switch t.name {
+		case "all":
+			if arg != "" {
+				err = permError("all takes no arguments")
+			}
+		case "include", "exists":
+			t.arg = strings.TrimPrefix(arg, ":")
+			if !strings.HasPrefix(arg, ":") || t.arg == "" {
+				err = permError("%s needs a domain", t.name)
+			}
+		case "a", "mx":
+			t.arg, t.cidr4, t.cidr6, err = parseSPFCIDR(arg)
+		case "ptr":
+			t.arg = strings.TrimPrefix(arg, ":")
+		case "ip4", "ip6":
+			t.arg, err = parseSPFIP(t.name, strings.TrimPrefix(arg, ":"))
+		default:
+			err = permError("unknown mechanism %s", t.name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+// parseSPFCIDR parses the [:domain-spec][/cidr4][//cidr6] argument of the a
+// and mx mechanisms.
+func parseSPFCIDR(arg string) (string, int, int, error) {
+	cidr4, cidr6 := -1, -1
+	spec := arg
+	var err error
+	if i := strings.Index(spec, "//"); i >= 0 {
+		v6 := spec[i+2:]
+		spec = spec[:i]
+		if cidr6, err = strconv.Atoi(v6); err != nil || cidr6 < 0 || cidr6 > 128 {
+			return "", 0, 0, permError("invalid IPv6 prefix length in %q", arg)
+		}
+	}
+	if i := strings.IndexByte(spec, '/'); i >= 0 {
+		v4 := spec[i+1:]
+		spec = spec[:i]
+		if cidr4, err = strconv.Atoi(v4); err != nil || cidr4 < 0 || cidr4 > 32 {
+			return "", 0, 0, permError("invalid IPv4 prefix length in %q", arg)
+		}
+	}
+	if spec != "" {
+		if !strings.HasPrefix(spec, ":") || len(spec) == 1 {
+			return "", 0, 0, permError("invalid domain in %q", arg)
+		}
+		spec = spec[1:]
+	}
+	return spec, cidr4, cidr6, nil
+}
+
+// parseSPFIP checks the network of an ip4 or ip6 mechanism and returns it in
+// CIDR notation.
+func parseSPFIP(mech, arg string) (string, error) {
+	addr, cidr := arg, ""
+	if i := strings.IndexByte(arg, '/'); i >= 0 {
+		addr, cidr = arg[:i], arg[i+1:]
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil || strings.Contains(addr, ":") == (mech == "ip4") {
+		return "", permError("invalid address in %s:%s", mech, arg)
+	}
+	max := 32
+	if mech == "ip6" {
+		max = 128
+	}
+	if cidr == "" {
+		return addr + "/" + strconv.Itoa(max), nil
+	}
+	if bits, err := strconv.Atoi(cidr); err != nil || bits < 0 || bits > max {
+		return "", permError("invalid prefix length in %s:%s", mech, arg)
+	}
+	return arg, nil
+}
+
+// validSPFDomain returns true if domain can be checked, RFC 7208 section 4.3.
+func validSPFDomain(domain string) bool {
+	if _, ok := dns.IsDomainName(domain); !ok {
+		return false
+	}
+	labels := dns.SplitDomainName(domain)
+	return len(labels) > 1
+}
+
+func qualifierResult(q byte) SPFResult {
+	switch q {
+	case '-':
+		return SPFFail
+	case '~':
+		return SPFSoftFail
+	case '?':
+		return SPFNeutral
+	}
+	return SPFPass
+}
+
+// errResult returns the result carried by err.
+func errResult(err error) SPFResult {
+	var e *spfError
+	if errors.As(err, &e) {
+		return e.result
+	}
+	return SPFTempError
+}
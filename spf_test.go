@@ -0,0 +1,134 @@
+package unbound
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestSPFMacro(t *testing.T) {
+	// Examples from RFC 7208, section 7.4.
+	c := &spfCheck{
+		ip:     net.ParseIP("192.0.2.3").To4(),
+		sender: "strong-bad@email.example.com",
+		local:  "strong-bad",
+		domain: "email.example.com.",
+	}
+	tests := map[string]string{
+		"%{s}":                     "strong-bad@email.example.com",
+		"%{o}":                     "email.example.com",
+		"%{d}":                     "email.example.com",
+		"%{d4}":                    "email.example.com",
+		"%{d2}":                    "example.com",
+		"%{d1}":                    "com",
+		"%{dr}":                    "com.example.email",
+		"%{d2r}":                   "example.email",
+		"%{l}":                     "strong-bad",
+		"%{l-}":                    "strong.bad",
+		"%{lr}":                    "strong-bad",
+		"%{lr-}":                   "bad.strong",
+		"%{l1r-}":                  "strong",
+		"%{ir}.%{v}._spf.%{d2}":    "3.2.0.192.in-addr._spf.example.com",
+		"%{lr-}.lp._spf.%{d2}":     "bad.strong.lp._spf.example.com",
+		"%{d2}.trusted-domains.%%": "example.com.trusted-domains.%",
+		"%{S}":                     "strong-bad%40email.example.com",
+	}
+	for in, out := range tests {
+		got, err := c.expand(in, c.domain)
+		if err != nil {
+			t.Errorf("expanding %s: %s", in, err)
+			continue
+		}
+		if got != out {
+			t.Errorf("expanding %s: expected %s, got %s", in, out, got)
+		}
+	}
+
+	c.ip = net.ParseIP("2001:db8::cb01")
+	got, _ := c.expand("%{ir}.%{v}._spf.%{d2}", c.domain)
+	if x := "1.0.b.c.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6._spf.example.com"; got != x {
+		t.Errorf("expected %s, got %s", x, got)
+	}
+	if _, err := c.expand("%{x}", c.domain); err == nil {
+		t.Error("expected error for unknown macro letter")
+	}
+}
+
+func TestParseSPF(t *testing.T) {
+	terms, err := parseSPF("v=spf1 +a/24 mx:example.org//64 -ip4:192.0.2.0/24 ip6:2001:db8::1 ~include:_spf.example.net redirect=_spf.example.com ?all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(terms) != 7 {
+		t.Fatalf("expected 7 terms, got %d", len(terms))
+	}
+	if a := terms[0]; a.name != "a" || a.cidr4 != 24 || a.cidr6 != -1 || a.arg != "" {
+		t.Errorf("unexpected a mechanism: %+v", a)
+	}
+	if mx := terms[1]; mx.arg != "example.org" || mx.cidr6 != 64 {
+		t.Errorf("unexpected mx mechanism: %+v", mx)
+	}
+	if ip6 := terms[3]; ip6.arg != "2001:db8::1/128" {
+		t.Errorf("unexpected ip6 mechanism: %+v", ip6)
+	}
+	if r := terms[5]; !r.modifier || r.name != "redirect" {
+		t.Errorf("unexpected redirect modifier: %+v", r)
+	}
+
+	for _, record := range []string{
+		"v=spf1 foo:example.com",
+		"v=spf1 all:example.com",
+		"v=spf1 ip4:2001:db8::1",
+		"v=spf1 ip4:192.0.2.1/33",
+		"v=spf1 include",
+		"v=spf1 redirect=a.example redirect=b.example",
+	} {
+		if _, err := parseSPF(record); err == nil {
+			t.Errorf("expected error parsing %q", record)
+		}
+	}
+}
+
+func TestCheckSPF(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+	for _, rr := range []string{
+		`example.com. TXT "v=spf1 ip4:192.0.2.0/24 include:_spf.example.com a:mail.example.com -all"`,
+		`_spf.example.com. TXT "v=spf1 ip6:2001:db8::/32 ~all"`,
+		`mail.example.com. A 198.51.100.25`,
+		`redirect.example. TXT "v=spf1 redirect=example.com"`,
+		`loop.example. TXT "v=spf1 include:loop.example -all"`,
+		`none.example. TXT "not spf"`,
+		`soft.example. TXT "v=spf1 ip4:192.0.2.0/24 ~all"`,
+	} {
+		if err := u.DataAdd(rr); err != nil {
+			t.Fatalf("failed to add local data: %s", err)
+		}
+	}
+	if _, err := u.Resolve("example.com.", dns.TypeTXT, dns.ClassINET); err != nil {
+		t.Skipf("can not resolve local data: %s", err)
+	}
+
+	tests := []struct {
+		ip     string
+		sender string
+		result SPFResult
+	}{
+		{"192.0.2.10", "user@example.com", SPFPass},
+		{"198.51.100.25", "user@example.com", SPFPass},
+		{"2001:db8::25", "user@example.com", SPFPass},
+		{"203.0.113.1", "user@soft.example", SPFSoftFail},
+		{"203.0.113.1", "user@example.com", SPFFail},
+		{"203.0.113.1", "user@redirect.example", SPFFail},
+		{"192.0.2.10", "redirect.example", SPFPass},
+		{"192.0.2.10", "user@loop.example", SPFPermError},
+		{"192.0.2.10", "user@none.example", SPFNone},
+	}
+	for _, tc := range tests {
+		res, err := u.CheckSPF(net.ParseIP(tc.ip), "mail.example.org", tc.sender)
+		if res != tc.result {
+			t.Errorf("%s from %s: expected %s, got %s (%v)", tc.sender, tc.ip, tc.result, res, err)
+		}
+	}
+}
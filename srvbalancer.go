@@ -0,0 +1,165 @@
+package unbound
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Bounds of the time an SRVBalancer skips an unhealthy target. The time
+// doubles with each consecutive failure.
+const (
+	srvMinBackoff = time.Second
+	srvMaxBackoff = 5 * time.Minute
+)
+
+// SRVTarget is a target picked by an SRVBalancer.
+type SRVTarget struct {
+	SRV   *dns.SRV
+	Addrs []net.IP // Addresses of the target
+}
+
+// HostPort returns the target and port of t in host:port form.
+func (t SRVTarget) HostPort() string {
+	return net.JoinHostPort(t.SRV.Target, strconv.Itoa(int(t.SRV.Port)))
+}
+
+// SRVBalancer is a client side load balancer over the targets of an SRV
+// record set. The records are re-resolved when their TTL expires. It is safe
+// for concurrent use.
+type SRVBalancer struct {
+	Unbound *Unbound
+	Service string
+	Proto   string
+	Name    string
+
+	mu        sync.Mutex
+	srv       []*dns.SRV
+	refresh   time.Time // When srv must be resolved again
+	unhealthy map[string]*srvHealth
+}
+
+// srvHealth is the health of a target marked unhealthy.
+type srvHealth struct {
+	failures int
+	retry    time.Time // When the target may be picked again
+}
+
+// NewSRVBalancer returns an SRVBalancer for the SRV records of service, proto
+// and name, see LookupSRV.
+func NewSRVBalancer(u *Unbound, service, proto, name string) *SRVBalancer {
+	return &SRVBalancer{Unbound: u, Service: service, Proto: proto, Name: name, unhealthy: make(map[string]*srvHealth)}
+}
+
+// Next picks a target and resolves its addresses. The target is picked
+// among the healthy targets with the lowest priority, randomly by weight as
+// specified in RFC 2782. If all targets are unhealthy, it is picked among all
+// targets. A target without addresses is marked unhealthy and another one is
+// picked.
+func (b *SRVBalancer) Next() (SRVTarget, error) {
+	srv, err := b.targets()
+	if err != nil {
+		return SRVTarget{}, err
+	}
+	for range srv {
+		rr := b.pick(srv)
+		addrs, err := b.Unbound.LookupIP(rr.Target)
+		if err == nil && len(addrs) > 0 {
+			return SRVTarget{SRV: rr, Addrs: addrs}, nil
+		}
+		b.MarkUnhealthy(rr)
+	}
+	return SRVTarget{}, errors.New("unbound: no SRV target with addresses for: " + serviceName(b.Service, b.Proto, b.Name))
+}
+
+// MarkUnhealthy marks the target of rr unhealthy: it is not picked until its
+// backoff has passed. The backoff doubles with each consecutive call.
+func (b *SRVBalancer) MarkUnhealthy(rr *dns.SRV) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	k := srvKey(rr)
+	h, ok := b.unhealthy[k]
+	if !ok {
+		h = new(srvHealth)
+		b.unhealthy[k] = h
+	}
+	h.failures++
+	backoff := srvMaxBackoff
+	if h.failures < 20 {
+		if d := srvMinBackoff << uint(h.failures-1); d < backoff {
+			backoff = d
+		}
+	}
+	h.retry = time.Now().Add(backoff)
+}
+
+// MarkHealthy marks the target of rr healthy again.
+func (b *SRVBalancer) MarkHealthy(rr *dns.SRV) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.unhealthy, srvKey(rr))
+}
+
+// targets returns the SRV records, resolving them when their TTL has expired.
+// If resolving fails the previous records are used, if there are any.
+func (b *SRVBalancer) targets() ([]*dns.SRV, error) {
+	b.mu.Lock()
+	srv, refresh := b.srv, b.refresh
+	b.mu.Unlock()
+	if srv != nil && time.Now().Before(refresh) {
+		return srv, nil
+	}
+
+	r, fresh, err := b.Unbound.lookupSRV(b.Service, b.Proto, b.Name)
+	if err == nil && r.Bogus {
+		err = fmt.Errorf("unbound: SRV records are bogus for %s: %s", serviceName(b.Service, b.Proto, b.Name), r.WhyBogus)
+	}
+	if err != nil {
+		if srv != nil {
+			return srv, nil
+		}
+		return nil, err
+	}
+	if len(fresh) == 0 {
+		return nil, errors.New("unbound: no SRV records for: " + serviceName(b.Service, b.Proto, b.Name))
+	}
+	// A single target of "." means the service is not available, RFC 2782.
+	if len(fresh) == 1 && fresh[0].Target == "." {
+		return nil, errors.New("unbound: service not available at: " + serviceName(b.Service, b.Proto, b.Name))
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.srv = fresh
+	b.refresh = time.Now().Add(watchRefresh(time.Duration(r.Ttl) * time.Second))
+	return fresh, nil
+}
+
+// pick picks a target from srv.
+func (b *SRVBalancer) pick(srv []*dns.SRV) *dns.SRV {
+	b.mu.Lock()
+	now := time.Now()
+	var healthy []*dns.SRV
+	for _, rr := range srv {
+		if h, ok := b.unhealthy[srvKey(rr)]; !ok || now.After(h.retry) {
+			healthy = append(healthy, rr)
+		}
+	}
+	b.mu.Unlock()
+	if len(healthy) == 0 {
+		healthy = append(healthy, srv...)
+	}
+
+	// The first record is picked from the lowest priority by weight.
+	byPriorityWeight(healthy).sort()
+	return healthy[0]
+}
+
+func srvKey(rr *dns.SRV) string {
+	return net.JoinHostPort(dns.CanonicalName(rr.Target), strconv.Itoa(int(rr.Port)))
+}
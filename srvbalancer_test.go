@@ -0,0 +1,77 @@
+package unbound
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestSRVBalancerPick(t *testing.T) {
+	srv := []*dns.SRV{
+		{Priority: 10, Weight: 60, Port: 80, Target: "a.example."},
+		{Priority: 10, Weight: 40, Port: 80, Target: "b.example."},
+		{Priority: 20, Weight: 0, Port: 80, Target: "backup.example."},
+	}
+	b := NewSRVBalancer(nil, "http", "tcp", "example.")
+
+	picked := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		picked[b.pick(srv).Target]++
+	}
+	if picked["backup.example."] != 0 {
+		t.Errorf("expected backup not to be picked, got %v", picked)
+	}
+	if picked["a.example."] < 450 || picked["b.example."] < 250 {
+		t.Errorf("expected picks to follow the weights, got %v", picked)
+	}
+
+	b.MarkUnhealthy(srv[0])
+	b.MarkUnhealthy(srv[1])
+	if rr := b.pick(srv); rr.Target != "backup.example." {
+		t.Errorf("expected backup to be picked, got %s", rr.Target)
+	}
+	b.MarkUnhealthy(srv[2])
+	if rr := b.pick(srv); rr.Priority != 10 {
+		t.Errorf("expected pick among all targets when all are unhealthy, got %s", rr.Target)
+	}
+	b.MarkHealthy(srv[1])
+	if rr := b.pick(srv); rr.Target != "b.example." {
+		t.Errorf("expected healthy target to be picked, got %s", rr.Target)
+	}
+
+	b.MarkUnhealthy(srv[0])
+	if h := b.unhealthy[srvKey(srv[0])]; h.failures != 2 || h.retry.IsZero() {
+		t.Errorf("unexpected health: %+v", h)
+	}
+}
+
+func TestSRVBalancer(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+	for _, rr := range []string{
+		`_http._tcp.example.com. SRV 10 0 8080 www.example.com.`,
+		`_http._tcp.example.com. SRV 20 0 8080 noaddr.example.com.`,
+		`www.example.com. A 192.0.2.80`,
+		`_none._tcp.example.com. SRV 0 0 0 .`,
+	} {
+		if err := u.DataAdd(rr); err != nil {
+			t.Fatalf("failed to add local data: %s", err)
+		}
+	}
+	if _, err := u.Resolve("_http._tcp.example.com.", dns.TypeSRV, dns.ClassINET); err != nil {
+		t.Skipf("can not resolve local data: %s", err)
+	}
+
+	b := NewSRVBalancer(u, "http", "tcp", "example.com.")
+	target, err := b.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.HostPort() != "www.example.com.:8080" || len(target.Addrs) != 1 {
+		t.Errorf("unexpected target: %+v", target)
+	}
+
+	if _, err := NewSRVBalancer(u, "none", "tcp", "example.com.").Next(); err == nil {
+		t.Error("expected error for unavailable service")
+	}
+}
@@ -0,0 +1,113 @@
+package unbound
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+	"golang.org/x/crypto/ssh"
+)
+
+// LookupSSHFP returns the DNS SSHFP records for the given host name.
+//
+// The records are returned regardless of their DNSSEC status, use
+// SSHFPHostKeyCallback to verify host keys.
+func (u *Unbound) LookupSSHFP(host string) (sshfp []*dns.SSHFP, err error) {
+	r, err := u.Resolve(host, dns.TypeSSHFP, dns.ClassINET)
+	if err != nil {
+		return nil, err
+	}
+	for _, rr := range r.Rr {
+		sshfp = append(sshfp, rr.(*dns.SSHFP))
+	}
+	return
+}
+
+// SSHFPHostKeyCallback returns an ssh.HostKeyCallback that accepts a host key
+// only when a secure SSHFP record of the host, see RFC 4255 and RFC 6594,
+// matches the key's algorithm and its SHA-1 or SHA-256 fingerprint. Host
+// keys of hosts without secure SSHFP records are rejected.
+func SSHFPHostKeyCallback(u *Unbound) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		host, _, err := net.SplitHostPort(hostname)
+		if err != nil {
+			host = hostname
+		}
+		if net.ParseIP(host) != nil {
+			return errors.New("unbound: can not verify host key of an address with SSHFP: " + host)
+		}
+		r, err := u.Resolve(host, dns.TypeSSHFP, dns.ClassINET)
+		if err != nil {
+			return err
+		}
+		if r.Bogus {
+			return fmt.Errorf("unbound: SSHFP records are bogus for %s: %s", host, r.WhyBogus)
+		}
+		if !r.Secure {
+			return errors.New("unbound: SSHFP records are not secure for: " + host)
+		}
+		var sshfp []*dns.SSHFP
+		for _, rr := range r.Rr {
+			if x, ok := rr.(*dns.SSHFP); ok {
+				sshfp = append(sshfp, x)
+			}
+		}
+		if len(sshfp) == 0 {
+			return errors.New("unbound: no SSHFP records for: " + host)
+		}
+		if !matchSSHFP(sshfp, key) {
+			return fmt.Errorf("unbound: %s host key for %s does not match its SSHFP records", key.Type(), host)
+		}
+		return nil
+	}
+}
+
+// matchSSHFP returns true if one of the records in sshfp matches key.
+func matchSSHFP(sshfp []*dns.SSHFP, key ssh.PublicKey) bool {
+	alg := sshfpAlgorithm(key.Type())
+	if alg == 0 {
+		return false
+	}
+	blob := key.Marshal()
+	for _, rr := range sshfp {
+		if rr.Algorithm != alg {
+			continue
+		}
+		var fp []byte
+		switch rr.Type {
+		case 1:
+			h := sha1.Sum(blob)
+			fp = h[:]
+		case 2:
+			h := sha256.Sum256(blob)
+			fp = h[:]
+		default:
+			continue
+		}
+		if strings.EqualFold(rr.FingerPrint, hex.EncodeToString(fp)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sshfpAlgorithm returns the SSHFP algorithm number for the SSH key type, or
+// zero if there is none.
+func sshfpAlgorithm(keyType string) uint8 {
+	switch keyType {
+	case ssh.KeyAlgoRSA:
+		return 1
+	case ssh.KeyAlgoDSA:
+		return 2
+	case ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521:
+		return 3
+	case ssh.KeyAlgoED25519:
+		return 4
+	}
+	return 0
+}
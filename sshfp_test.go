@@ -0,0 +1,37 @@
+package unbound
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/miekg/dns"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestMatchSSHFP(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := sha256.Sum256(key.Marshal())
+
+	rr := &dns.SSHFP{Algorithm: 4, Type: 2, FingerPrint: hex.EncodeToString(fp[:])}
+	if !matchSSHFP([]*dns.SSHFP{rr}, key) {
+		t.Error("expected SSHFP record to match the key")
+	}
+	rr.Algorithm = 1
+	if matchSSHFP([]*dns.SSHFP{rr}, key) {
+		t.Error("expected SSHFP record with other algorithm not to match")
+	}
+	rr.Algorithm, rr.FingerPrint = 4, hex.EncodeToString(make([]byte, 32))
+	if matchSSHFP([]*dns.SSHFP{rr}, key) {
+		t.Error("expected SSHFP record with other fingerprint not to match")
+	}
+}
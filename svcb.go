@@ -0,0 +1,244 @@
+package unbound
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/miekg/dns"
+)
+
+// maxAliasHops is the number of AliasMode records followed before giving up.
+const maxAliasHops = 8
+
+// ServiceBinding is a ServiceMode SVCB or HTTPS record, see RFC 9460.
+type ServiceBinding struct {
+	Priority      uint16    // SvcPriority, never zero
+	Target        string    // TargetName, with "." replaced by the owner name
+	Port          uint16    // Port parameter, zero when not present
+	ALPN          []string  // Alpn parameter
+	NoDefaultALPN bool      // True if the no-default-alpn parameter is present
+	IPv4Hint      []net.IP  // Ipv4hint parameter
+	IPv6Hint      []net.IP  // Ipv6hint parameter
+	RR            *dns.SVCB // The record
+}
+
+// LookupSVCB returns the ServiceMode SVCB records for name sorted by priority,
+// and randomized within a priority. AliasMode records are followed. An empty
+// result means the name offers no service bindings, and the client should
+// connect to the name (or the last alias target) directly.
+func (u *Unbound) LookupSVCB(name string) (sb []ServiceBinding, err error) {
+	return u.lookupServiceBindings(name, dns.TypeSVCB)
+}
+
+// LookupHTTPS returns the ServiceMode HTTPS records for name sorted by
+// priority, and randomized within a priority. AliasMode records are
+// followed, as in LookupSVCB.
+func (u *Unbound) LookupHTTPS(name string) (sb []ServiceBinding, err error) {
+	return u.lookupServiceBindings(name, dns.TypeHTTPS)
+}
+
+func (u *Unbound) lookupServiceBindings(name string, qtype uint16) ([]ServiceBinding, error) {
+	for i := 0; i < maxAliasHops; i++ {
+		r, err := u.Resolve(name, qtype, dns.ClassINET)
+		if err != nil {
+			return nil, err
+		}
+		owner := name
+		if r.CanonName != "" {
+			owner = r.CanonName
+		}
+
+		var svcb []*dns.SVCB
+		alias := ""
+		for _, rr := range r.Rr {
+			var x *dns.SVCB
+			switch rr := rr.(type) {
+			case *dns.SVCB:
+				x = rr
+			case *dns.HTTPS:
+				x = &rr.SVCB
+			default:
+				continue
+			}
+			if x.Priority == 0 {
+				alias = x.Target
+				break
+			}
+			svcb = append(svcb, x)
+		}
+
+		if alias == "" {
+			sb := make([]ServiceBinding, 0, len(svcb))
+			for _, x := range svcb {
+				sb = append(sb, newServiceBinding(x, owner))
+			}
+			byPriority(sb).sort()
+			return sb, nil
+		}
+		if alias == "." {
+			// The service is not available, RFC 9460 section 2.5.1.
+			return nil, nil
+		}
+		name = alias
+	}
+	return nil, errors.New("unbound: too many AliasMode records for: " + name)
+}
+
+// newServiceBinding returns the ServiceBinding for the ServiceMode record rr
+// owned by owner.
+func newServiceBinding(rr *dns.SVCB, owner string) ServiceBinding {
+	sb := ServiceBinding{Priority: rr.Priority, Target: rr.Target, RR: rr}
+	if sb.Target == "." {
+		sb.Target = owner
+	}
+	for _, kv := range rr.Value {
+		switch x := kv.(type) {
+		case *dns.SVCBPort:
+			sb.Port = x.Port
+		case *dns.SVCBAlpn:
+			sb.ALPN = x.Alpn
+		case *dns.SVCBNoDefaultAlpn:
+			sb.NoDefaultALPN = true
+		case *dns.SVCBIPv4Hint:
+			sb.IPv4Hint = x.Hint
+		case *dns.SVCBIPv6Hint:
+			sb.IPv6Hint = x.Hint
+		}
+	}
+	return sb
+}
+
+// httpALPN returns the protocols of sb that an http.Transport can speak, in
+// the order of preference of sb. The default protocol http/1.1 is included
+// unless NoDefaultALPN is set.
+func (sb ServiceBinding) httpALPN() []string {
+	var protos []string
+	seen := false
+	for _, p := range sb.ALPN {
+		switch p {
+		case "h2":
+			protos = append(protos, p)
+		case "http/1.1":
+			protos = append(protos, p)
+			seen = true
+		}
+	}
+	if !sb.NoDefaultALPN && !seen {
+		protos = append(protos, "http/1.1")
+	}
+	return protos
+}
+
+// HTTPSTransport returns a clone of http.DefaultTransport that connects to
+// https URLs using the HTTPS records of the host. The bindings are tried in
+// order, using their target, port and alpn parameters, and connecting to the
+// ipv4hint and ipv6hint addresses when present and to the addresses of the
+// target otherwise. Without usable bindings the addresses of the host are
+// used. The certificate is verified against the host in the URL; config
+// may be nil.
+// This method is not found in Unbound.
+func (u *Unbound) HTTPSTransport(config *tls.Config) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return u.dialHTTPS(ctx, network, addr, config)
+	}
+	return t
+}
+
+// httpsEndpoint is an address to connect to and the protocols to offer.
+type httpsEndpoint struct {
+	addr string
+	alpn []string
+}
+
+func (u *Unbound) dialHTTPS(ctx context.Context, network, addr string, config *tls.Config) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := u.httpsEndpoints(host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	for _, e := range endpoints {
+		var conn net.Conn
+		conn, err = d.DialContext(ctx, network, e.addr)
+		if err != nil {
+			continue
+		}
+		cfg := &tls.Config{}
+		if config != nil {
+			cfg = config.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = host
+		}
+		cfg.NextProtos = e.alpn
+		tc := tls.Client(conn, cfg)
+		if err = tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			continue
+		}
+		return tc, nil
+	}
+	if err == nil {
+		err = errors.New("unbound: no addresses found for: " + host)
+	}
+	return nil, err
+}
+
+// httpsEndpoints returns the endpoints for host in order of preference.
+func (u *Unbound) httpsEndpoints(host, port string) ([]httpsEndpoint, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []httpsEndpoint{{net.JoinHostPort(host, port), []string{"h2", "http/1.1"}}}, nil
+	}
+
+	// Non default ports use a prefixed name, RFC 9460 section 9.1.
+	qname := host
+	if port != "443" {
+		qname = "_" + port + "._https." + host
+	}
+
+	var endpoints []httpsEndpoint
+	// A failing HTTPS lookup is not fatal, we fall back to the addresses.
+	sb, _ := u.LookupHTTPS(qname)
+	for _, b := range sb {
+		alpn := b.httpALPN()
+		if len(alpn) == 0 {
+			continue
+		}
+		p := port
+		if b.Port != 0 {
+			p = strconv.Itoa(int(b.Port))
+		}
+		target := b.Target
+		if dns.Fqdn(target) == dns.Fqdn(qname) {
+			target = host
+		}
+		ips := append(append([]net.IP(nil), b.IPv4Hint...), b.IPv6Hint...)
+		if len(ips) == 0 {
+			ips, _ = u.LookupIP(target)
+		}
+		for _, ip := range ips {
+			endpoints = append(endpoints, httpsEndpoint{net.JoinHostPort(ip.String(), p), alpn})
+		}
+	}
+	if len(endpoints) > 0 {
+		return endpoints, nil
+	}
+
+	ips, err := u.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		endpoints = append(endpoints, httpsEndpoint{net.JoinHostPort(ip.String(), port), []string{"h2", "http/1.1"}})
+	}
+	return endpoints, nil
+}
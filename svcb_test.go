@@ -0,0 +1,107 @@
+package unbound
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestServiceBinding(t *testing.T) {
+	rr, err := dns.NewRR(`example.com. 300 IN HTTPS 1 . alpn="h3,h2" port=8443 ipv4hint=192.0.2.1 ipv6hint=2001:db8::1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb := newServiceBinding(&rr.(*dns.HTTPS).SVCB, "example.com.")
+	if sb.Target != "example.com." || sb.Port != 8443 || len(sb.IPv4Hint) != 1 || len(sb.IPv6Hint) != 1 {
+		t.Fatalf("unexpected service binding: %+v", sb)
+	}
+	if alpn := sb.httpALPN(); !reflect.DeepEqual(alpn, []string{"h2", "http/1.1"}) {
+		t.Errorf("expected h2 and http/1.1, got %v", alpn)
+	}
+
+	rr, err = dns.NewRR(`example.com. 300 IN HTTPS 2 svc.example.net. alpn=h3 no-default-alpn`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb = newServiceBinding(&rr.(*dns.HTTPS).SVCB, "example.com.")
+	if alpn := sb.httpALPN(); len(alpn) != 0 {
+		t.Errorf("expected no usable protocols, got %v", alpn)
+	}
+}
+
+func TestByPrioritySort(t *testing.T) {
+	sb := []ServiceBinding{{Priority: 3}, {Priority: 1}, {Priority: 2}, {Priority: 1}}
+	byPriority(sb).sort()
+	for i := 1; i < len(sb); i++ {
+		if sb[i-1].Priority > sb[i].Priority {
+			t.Fatalf("service bindings not sorted by priority: %+v", sb)
+		}
+	}
+}
+
+func TestLookupHTTPSAlias(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+	if err := u.ZoneAdd("example.test.", "static"); err != nil {
+		t.Fatal(err)
+	}
+	for _, rr := range []string{
+		`example.test. HTTPS 0 svc.example.test.`,
+		`example.test. A 192.0.2.1`,
+		`svc.example.test. HTTPS 1 . alpn=h2`,
+		`svc.example.test. A 192.0.2.10`,
+		`_8443._https.example.test. HTTPS 0 .`,
+	} {
+		if err := u.DataAdd(rr); err != nil {
+			t.Fatalf("failed to add local data: %s", err)
+		}
+	}
+	if _, err := u.Resolve("example.test.", dns.TypeHTTPS, dns.ClassINET); err != nil {
+		t.Skipf("can not resolve local data: %s", err)
+	}
+
+	sb, err := u.LookupHTTPS("example.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sb) != 1 || sb[0].Target != "svc.example.test." || !reflect.DeepEqual(sb[0].ALPN, []string{"h2"}) {
+		t.Fatalf("unexpected service bindings: %+v", sb)
+	}
+	endpoints, err := u.httpsEndpoints("example.test", "443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []httpsEndpoint{{"192.0.2.10:443", []string{"h2", "http/1.1"}}}; !reflect.DeepEqual(endpoints, want) {
+		t.Errorf("expected %v, got %v", want, endpoints)
+	}
+
+	// An alias to "." means there is no service, the addresses of the host are used.
+	sb, err = u.LookupHTTPS("_8443._https.example.test.")
+	if err != nil || sb != nil {
+		t.Fatalf("expected no service bindings, got %+v, %v", sb, err)
+	}
+	endpoints, err = u.httpsEndpoints("example.test", "8443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []httpsEndpoint{{"192.0.2.1:8443", []string{"h2", "http/1.1"}}}; !reflect.DeepEqual(endpoints, want) {
+		t.Errorf("expected %v, got %v", want, endpoints)
+	}
+}
+
+func ExampleUnbound_LookupHTTPS() {
+	u := New()
+	defer u.Destroy()
+	if err := u.ResolvConf("/etc/resolv.conf"); err != nil {
+		return
+	}
+	sb, err := u.LookupHTTPS("cloudflare.com.")
+	if err != nil {
+		return
+	}
+	for _, b := range sb {
+		fmt.Printf("%d %s %v\n", b.Priority, b.Target, b.ALPN)
+	}
+}
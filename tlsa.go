@@ -0,0 +1,53 @@
+package unbound
+
+import (
+	"crypto/x509"
+	"errors"
+
+	"github.com/miekg/dns"
+)
+
+// NewTLSA returns a TLSA record for cert with the given certificate usage,
+// selector and matching type. The owner name is not set, use dns.TLSAName to
+// create it.
+func NewTLSA(usage, selector, matching uint8, cert *x509.Certificate) (*dns.TLSA, error) {
+	rr := &dns.TLSA{Hdr: dns.RR_Header{Rrtype: dns.TypeTLSA, Class: dns.ClassINET}}
+	if !usableTLSA(&dns.TLSA{Usage: usage, Selector: selector, MatchingType: matching}) {
+		return nil, errors.New("unbound: unknown TLSA usage, selector or matching type")
+	}
+	if err := rr.Sign(int(usage), int(selector), int(matching), cert); err != nil {
+		return nil, err
+	}
+	return rr, nil
+}
+
+// TLSACheck is the outcome of checking a certificate chain against a single
+// TLSA record.
+type TLSACheck struct {
+	TLSA *dns.TLSA
+	Err  error // Reason the record does not match, nil if it does
+}
+
+// CheckTLSA checks the certificate chain certs, leaf first, against each of
+// the TLSA records returned by LookupTLSA for service, proto and name. This
+// is meant for operators checking their published records: unlike
+// DANEVerifier it also uses records from insecure answers. PKIX usages are
+// checked against the system roots.
+// This method is not found in Unbound.
+func (u *Unbound) CheckTLSA(service, proto, name string, certs []*x509.Certificate) ([]TLSACheck, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("unbound: no certificates to check")
+	}
+	tlsa, err := u.LookupTLSA(service, proto, dns.Fqdn(name))
+	if err != nil {
+		return nil, err
+	}
+	if len(tlsa) == 0 {
+		return nil, errors.New("unbound: no TLSA records for: " + name)
+	}
+	checks := make([]TLSACheck, len(tlsa))
+	for i, rr := range tlsa {
+		checks[i] = TLSACheck{TLSA: rr, Err: checkTLSA(rr, certs, []string{name}, nil)}
+	}
+	return checks, nil
+}
@@ -0,0 +1,43 @@
+package unbound
+
+import (
+	"crypto/x509"
+	"strings"
+	"testing"
+)
+
+func TestNewTLSA(t *testing.T) {
+	certs := newTestChain(t, "www.example")
+	rr, err := NewTLSA(TLSADANEEE, TLSASPKI, TLSASHA2_256, certs[0])
+	if err != nil {
+		t.Fatalf("failed to create TLSA record: %s", err)
+	}
+	if len(rr.Certificate) != 64 {
+		t.Errorf("expected SHA2-256 association data, got %s", rr.Certificate)
+	}
+	if err := checkTLSA(rr, certs, []string{"www.example."}, nil); err != nil {
+		t.Errorf("expected TLSA record to match, got %s", err)
+	}
+	if _, err := NewTLSA(4, TLSASPKI, TLSASHA2_256, certs[0]); err == nil {
+		t.Error("expected error for unknown usage")
+	}
+}
+
+func TestCheckTLSAReason(t *testing.T) {
+	certs := newTestChain(t, "www.example")
+	other := newTestChain(t, "www.example")
+
+	rr, _ := NewTLSA(TLSADANETA, TLSACert, TLSASHA2_256, certs[1])
+	err := checkTLSA(rr, certs, []string{"mail.example."}, nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "matches certificate 1") {
+		t.Errorf("expected name mismatch for certificate 1, got %v", err)
+	}
+	rr, _ = NewTLSA(TLSADANEEE, TLSACert, TLSAFull, other[0])
+	if err := checkTLSA(rr, certs, []string{"www.example."}, nil); err == nil {
+		t.Error("expected leaf mismatch")
+	}
+	rr, _ = NewTLSA(TLSAPKIXEE, TLSASPKI, TLSASHA2_512, certs[0])
+	if err := checkTLSA(rr, certs, []string{"www.example."}, x509.NewCertPool()); err == nil || !strings.HasPrefix(err.Error(), "PKIX") {
+		t.Errorf("expected PKIX failure, got %v", err)
+	}
+}
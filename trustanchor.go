@@ -0,0 +1,289 @@
+package unbound
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// TrustAnchor is a DS or DNSKEY record loaded as trust anchor.
+type TrustAnchor struct {
+	RR         dns.RR // The anchor, either a *dns.DS or a *dns.DNSKEY
+	Owner      string // Owner name of the anchor
+	KeyTag     uint16 // Key tag of the (referenced) key
+	Algorithm  uint8  // Algorithm of the (referenced) key
+	DigestType uint8  // Digest type of Digest
+	Digest     string // Digest from the DS, or the SHA-256 digest of the DNSKEY
+}
+
+// TrustAnchors returns the trust anchors added with AddTa, AddTaRR, AddTaFile
+// and TrustedKeys, in the order they were added.
+// This method is not found in Unbound.
+func (u *Unbound) TrustAnchors() []TrustAnchor {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	ta := make([]TrustAnchor, len(u.ta))
+	copy(ta, u.ta)
+	return ta
+}
+
+// addTrustAnchors calls f on the current context when none of ta is already
+// loaded and records ta when f succeeds.
+func (u *Unbound) addTrustAnchors(ta []TrustAnchor, f ctxFunc) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for i := range ta {
+		for j := range u.ta {
+			if ta[i].equal(u.ta[j]) {
+				return fmt.Errorf("unbound: duplicate trust anchor for %s with key tag %d", ta[i].Owner, ta[i].KeyTag)
+			}
+		}
+	}
+	if err := u.applyLocked(f); err != nil {
+		return err
+	}
+	u.ta = append(u.ta, ta...)
+	return nil
+}
+
+// newTrustAnchor checks rr and returns it as a TrustAnchor.
+func newTrustAnchor(rr dns.RR) (TrustAnchor, error) {
+	h := rr.Header()
+	if h.Class != dns.ClassINET {
+		return TrustAnchor{}, fmt.Errorf("unbound: trust anchor for %s has class %s", h.Name, dns.Class(h.Class))
+	}
+	switch x := rr.(type) {
+	case *dns.DS:
+		n, ok := digestLen[x.DigestType]
+		if !ok {
+			return TrustAnchor{}, fmt.Errorf("unbound: trust anchor for %s has unknown digest type %d", h.Name, x.DigestType)
+		}
+		d, err := hex.DecodeString(x.Digest)
+		if err != nil || len(d) != n {
+			return TrustAnchor{}, fmt.Errorf("unbound: trust anchor for %s has malformed digest", h.Name)
+		}
+		return TrustAnchor{
+			RR:         x,
+			Owner:      dns.CanonicalName(h.Name),
+			KeyTag:     x.KeyTag,
+			Algorithm:  x.Algorithm,
+			DigestType: x.DigestType,
+			Digest:     strings.ToUpper(x.Digest),
+		}, nil
+	case *dns.DNSKEY:
+		if x.Flags&dns.ZONE == 0 {
+			return TrustAnchor{}, fmt.Errorf("unbound: trust anchor for %s is not a zone key", h.Name)
+		}
+		if x.Protocol != 3 {
+			return TrustAnchor{}, fmt.Errorf("unbound: trust anchor for %s has protocol %d", h.Name, x.Protocol)
+		}
+		ds := x.ToDS(dns.SHA256)
+		if ds == nil {
+			return TrustAnchor{}, fmt.Errorf("unbound: trust anchor for %s has malformed public key", h.Name)
+		}
+		return TrustAnchor{
+			RR:         x,
+			Owner:      dns.CanonicalName(h.Name),
+			KeyTag:     x.KeyTag(),
+			Algorithm:  x.Algorithm,
+			DigestType: dns.SHA256,
+			Digest:     strings.ToUpper(ds.Digest),
+		}, nil
+	}
+	return TrustAnchor{}, fmt.Errorf("unbound: trust anchor for %s is not a DS or DNSKEY but %s", h.Name, dns.Type(h.Rrtype))
+}
+
+// digestLen holds the length of the DS digest types.
+var digestLen = map[uint8]int{
+	dns.SHA1:   20,
+	dns.SHA256: 32,
+	dns.SHA384: 48,
+}
+
+// equal returns true when ta and o refer to the same key. A DS and a DNSKEY
+// are equal if the DS is a digest of the DNSKEY.
+func (ta TrustAnchor) equal(o TrustAnchor) bool {
+	if ta.Owner != o.Owner || ta.KeyTag != o.KeyTag || ta.Algorithm != o.Algorithm {
+		return false
+	}
+	if ta.DigestType == o.DigestType {
+		return ta.Digest == o.Digest
+	}
+	// Different digest types, only comparable when one of the two is a key.
+	if k, ok := ta.RR.(*dns.DNSKEY); ok {
+		ds := k.ToDS(o.DigestType)
+		return ds != nil && strings.EqualFold(ds.Digest, o.Digest)
+	}
+	if k, ok := o.RR.(*dns.DNSKEY); ok {
+		ds := k.ToDS(ta.DigestType)
+		return ds != nil && strings.EqualFold(ds.Digest, ta.Digest)
+	}
+	return false
+}
+
+// checkTrustAnchors checks the anchors in rrs and rejects duplicates among them.
+func checkTrustAnchors(rrs []dns.RR) ([]TrustAnchor, error) {
+	if len(rrs) == 0 {
+		return nil, errors.New("unbound: no trust anchors found")
+	}
+	ta := make([]TrustAnchor, 0, len(rrs))
+	for _, rr := range rrs {
+		t, err := newTrustAnchor(rr)
+		if err != nil {
+			return nil, err
+		}
+		for i := range ta {
+			if t.equal(ta[i]) {
+				return nil, fmt.Errorf("unbound: duplicate trust anchor for %s with key tag %d", t.Owner, t.KeyTag)
+			}
+		}
+		ta = append(ta, t)
+	}
+	return ta, nil
+}
+
+// parseTa parses a single trust anchor in zone file format, as used by
+// ub_ctx_add_ta.
+func parseTa(s string) ([]TrustAnchor, error) {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		return nil, fmt.Errorf("unbound: malformed trust anchor: %s", err)
+	}
+	if rr == nil {
+		return nil, errors.New("unbound: no trust anchors found")
+	}
+	return checkTrustAnchors([]dns.RR{rr})
+}
+
+// parseTaFile parses a trust anchor file in zone file format, as used by
+// ub_ctx_add_ta_file.
+func parseTaFile(fname string) ([]TrustAnchor, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rrs []dns.RR
+	zp := dns.NewZoneParser(f, ".", fname)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		rrs = append(rrs, rr)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, fmt.Errorf("unbound: malformed trust anchor file: %s", err)
+	}
+	return checkTrustAnchors(rrs)
+}
+
+// parseTrustedKeys parses a BIND style trusted-keys file, as used by
+// ub_ctx_trustedkeys. Only trusted-keys clauses hold anchors, other clauses
+// are skipped. The managed-keys and trust-anchors clauses are rejected:
+// their initial keys are RFC 5011 managed anchors, not static ones.
+func parseTrustedKeys(fname string) ([]TrustAnchor, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var rrs []dns.RR
+	tok := bindTokens(string(buf))
+	for i := 0; i < len(tok); i++ {
+		clause := tok[i]
+		if i+1 >= len(tok) || tok[i+1] != "{" {
+			continue
+		}
+		if clause == "managed-keys" || clause == "trust-anchors" {
+			return nil, fmt.Errorf("unbound: %s clause in %s is not supported, use trusted-keys", clause, fname)
+		}
+		i += 2
+		keys := clause == "trusted-keys"
+		depth := 1
+		var entry []string
+		for ; i < len(tok) && depth > 0; i++ {
+			switch tok[i] {
+			case "{":
+				depth++
+			case "}":
+				depth--
+			case ";":
+				if keys && depth == 1 && len(entry) > 0 {
+					rr, err := trustedKeyRR(entry)
+					if err != nil {
+						return nil, fmt.Errorf("unbound: malformed trusted key in %s: %s", fname, err)
+					}
+					rrs = append(rrs, rr)
+				}
+				entry = entry[:0]
+			default:
+				entry = append(entry, tok[i])
+			}
+		}
+		if depth > 0 {
+			return nil, fmt.Errorf("unbound: malformed trusted keys file %s: unbalanced braces", fname)
+		}
+		i-- // point at the closing brace, the loop skips it
+	}
+	return checkTrustAnchors(rrs)
+}
+
+// trustedKeyRR converts a trusted-keys entry, <name> <flags> <protocol>
+// <algorithm> <key>, to a DNSKEY.
+func trustedKeyRR(entry []string) (dns.RR, error) {
+	if len(entry) < 5 {
+		return nil, errors.New("too few fields")
+	}
+	data := strings.Join(entry[4:], "")
+	return dns.NewRR(fmt.Sprintf("%s IN DNSKEY %s %s %s %s", dns.Fqdn(entry[0]), entry[1], entry[2], entry[3], data))
+}
+
+// bindTokens splits s into the tokens of a BIND configuration file: braces,
+// semicolons, quoted strings and words. Quotes are removed and comments are
+// skipped.
+func bindTokens(s string) []string {
+	var tok []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#' || strings.HasPrefix(s[i:], "//"):
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(s[i:], "/*"):
+			j := strings.Index(s[i+2:], "*/")
+			if j < 0 {
+				return tok
+			}
+			i += j + 4
+		case c == '{' || c == '}' || c == ';':
+			tok = append(tok, string(c))
+			i++
+		case c == '"':
+			j := strings.IndexByte(s[i+1:], '"')
+			if j < 0 {
+				j = len(s) - i - 1
+			}
+			tok = append(tok, strings.Join(strings.Fields(s[i+1:i+1+j]), ""))
+			i += j + 2
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\r\n{};\"#", rune(s[j])) {
+				j++
+			}
+			tok = append(tok, s[i:j])
+			i = j
+		}
+	}
+	return tok
+}
@@ -0,0 +1,111 @@
+package unbound
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const rootDS = ". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D"
+
+func TestTrustAnchors(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+
+	if err := u.AddTa(rootDS); err != nil {
+		t.Fatalf("failed to add trust anchor: %s", err)
+	}
+	if err := u.AddTa(rootDS); err == nil {
+		t.Fatal("expected error adding duplicate trust anchor")
+	}
+	if err := u.AddTa(". IN DS 20326 8 2 E06D44"); err == nil {
+		t.Fatal("expected error adding trust anchor with short digest")
+	}
+	if err := u.AddTa("miek.nl. IN A 127.0.0.1"); err == nil {
+		t.Fatal("expected error adding A record as trust anchor")
+	}
+	if err := u.AddTaFile("tutorial6/keys"); err != nil {
+		t.Fatalf("failed to add trust anchor file: %s", err)
+	}
+
+	ta := u.TrustAnchors()
+	if len(ta) != 3 {
+		t.Fatalf("expected 3 trust anchors, got %d", len(ta))
+	}
+	if ta[0].Owner != "." || ta[0].KeyTag != 20326 || ta[0].Algorithm != dns.RSASHA256 {
+		t.Errorf("unexpected root trust anchor: %+v", ta[0])
+	}
+	if ta[1].Owner != "nlnetlabs.nl." || ta[1].DigestType != dns.SHA256 || len(ta[1].Digest) != 64 {
+		t.Errorf("unexpected nlnetlabs.nl. trust anchor: %+v", ta[1])
+	}
+	if err := u.AddTaRR(ta[1].RR.(*dns.DNSKEY).ToDS(dns.SHA1)); err == nil {
+		t.Fatal("expected error adding DS of loaded DNSKEY")
+	}
+}
+
+func TestTrustedKeys(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "trusted-keys")
+	conf := `// comment
+options { directory "/var/named"; };
+trusted-keys {
+	"nlnetlabs.nl." 257 3 8 "AwEAAbwL6LuXTLXtb23CsXhpkxxyGbEFUROh/L8BWA1EEF8LdQ4Rmsj4
+		D5D8uAnRFDkNhM6XiII9xcsavwBGNwHxzUaij4MZQu1vrzcfGIJLcC1Q
+		paZmSH9WqIYFQci+T4s4UfDrrS96wO/H0nJvFmavWVX/7p1Q6dv0Arwz
+		XMXaHGrRVdEgK2MDS3dFRngx5JC5fwD7YnwH08EAoFRjdAoXe+etOAeG
+		aOT9IGjVM5LKkN2k6fIRvZ2l9eu5/o+h5L+kpDRcapW2QiL21hCcmwpW
+		50Llfx9Ovk+M7TBjp4iT7Tc8gLzRZr24LmXEyABb54WW3aoF5k8DZPot
+		9ogUjxVN/dM="; # KSK
+};
+`
+	if err := os.WriteFile(fname, []byte(conf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ta, err := parseTrustedKeys(fname)
+	if err != nil {
+		t.Fatalf("failed to parse trusted keys: %s", err)
+	}
+	if len(ta) != 1 {
+		t.Fatalf("expected 1 trust anchor, got %d", len(ta))
+	}
+	if _, ok := ta[0].RR.(*dns.DNSKEY); !ok || ta[0].Owner != "nlnetlabs.nl." {
+		t.Errorf("unexpected trust anchor: %+v", ta[0])
+	}
+
+	// Initial keys are RFC 5011 managed anchors, they must not become static.
+	conf = `trust-anchors {
+	. initial-ds 20326 8 2 "E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D";
+};
+`
+	if err := os.WriteFile(fname, []byte(conf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseTrustedKeys(fname); err == nil {
+		t.Error("expected error for trust-anchors clause")
+	}
+}
+
+func TestAddTaFileReplay(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+
+	fname := filepath.Join(t.TempDir(), "root.key")
+	if err := os.WriteFile(fname, []byte(rootDS+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.AddTaFile(fname); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(fname); err != nil {
+		t.Fatal(err)
+	}
+	// The rebuild replays the parsed anchor, not the removed file.
+	if err := u.AddNegativeTrustAnchor("example.org.", time.Time{}); err != nil {
+		t.Fatalf("rebuild after removing the trust anchor file failed: %s", err)
+	}
+	if ta := u.TrustAnchors(); len(ta) != 1 || ta[0].KeyTag != 20326 {
+		t.Errorf("unexpected trust anchors: %+v", ta)
+	}
+}
@@ -22,6 +22,7 @@
 // The asynchronous functions are implemented using goroutines. This
 // means the following functions are not useful in Go and therefor
 // not implemented: ub_fd, ub_wait, ub_poll, ub_process and ub_cancel.
+// Unbound's own background worker is always a thread, see Async.
 //
 // Unbound's ub_result (named Result in the package) has been modified.
 // An extra field has been added, 'Rr', which is a []dns.RR.
@@ -58,9 +59,11 @@ import "C"
 
 import (
 	"encoding/binary"
+	"errors"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unsafe"
 
@@ -69,10 +72,66 @@ import (
 
 // Unbound wraps the C structures and performs the resolving of names.
 type Unbound struct {
-	ctx     *C.struct_ub_ctx
+	ctx     *ubContext
 	version [3]int
+
+	mu        sync.RWMutex // protects ctx, conf, nta, ta, zones and destroyed
+	conf      []ctxFunc    // successful configuration calls, replayed by rebuild
+	nta       map[string]*negativeTrustAnchor
+	ta        []TrustAnchor
+	zones     []Zone
+	destroyed bool // set by Destroy, rebuild must not create a new context
+
+	sts mtastsCache // MTA-STS policies, see LookupMTASTS
+}
+
+// ubContext is an Unbound context and the resolves using it. A context that
+// is replaced by rebuild or destroyed is deleted when its last resolve
+// finishes.
+type ubContext struct {
+	ctx *C.struct_ub_ctx
+
+	mu      sync.Mutex // protects refs and retired
+	refs    int        // resolves in progress
+	retired bool
+}
+
+// acquire registers a resolve using c. The caller must hold the read lock of
+// the Unbound that c belongs to, so c is not retired concurrently.
+func (c *ubContext) acquire() {
+	c.mu.Lock()
+	c.refs++
+	c.mu.Unlock()
+}
+
+// release unregisters a resolve, deleting c if it was the last one of a
+// retired context.
+func (c *ubContext) release() {
+	c.mu.Lock()
+	c.refs--
+	del := c.retired && c.refs == 0
+	c.mu.Unlock()
+	if del {
+		C.ub_ctx_delete(c.ctx)
+	}
+}
+
+// retire marks c as no longer in use, deleting it when no resolves are using
+// it.
+func (c *ubContext) retire() {
+	c.mu.Lock()
+	c.retired = true
+	del := c.refs == 0
+	c.mu.Unlock()
+	if del {
+		C.ub_ctx_delete(c.ctx)
+	}
 }
 
+// ctxFunc is a configuration call on an Unbound context. It returns
+// Unbound's error code.
+type ctxFunc func(ctx *C.struct_ub_ctx) C.int
+
 // Result is Unbound's ub_result adapted for Go.
 type Result struct {
 	Qname        string        // Text string, original question
@@ -138,35 +197,99 @@ func (u *Unbound) haveTTLFeature() bool {
 	}
 }
 
-// New wraps Unbound's ub_ctx_create.
+// New wraps Unbound's ub_ctx_create. Unlike libunbound, which defaults to
+// fork mode, the context is set to use threads, see Async.
 func New() *Unbound {
 	u := new(Unbound)
-	u.ctx = C.ub_ctx_create()
+	u.ctx = &ubContext{ctx: C.ub_ctx_create()}
 	u.version = u.Version()
+	u.nta = make(map[string]*negativeTrustAnchor)
+	// ub_ctx_async only fails after the context is finalized by its first
+	// resolve, so this can not fail.
+	u.Async(true)
 	return u
 }
 
 // Destroy wraps Unbound's ub_ctx_delete.
 func (u *Unbound) Destroy() {
-	C.ub_ctx_delete(u.ctx)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for zone, n := range u.nta {
+		n.stop()
+		delete(u.nta, zone)
+	}
+	u.destroyed = true
+	u.ctx.retire()
+}
+
+// apply calls f on the current context and records it when it succeeds, so
+// the configuration can be replayed on a new context.
+func (u *Unbound) apply(f ctxFunc) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.applyLocked(f)
+}
+
+// applyLocked is apply for callers that hold u.mu for writing.
+func (u *Unbound) applyLocked(f ctxFunc) error {
+	if err := newError(int(f(u.ctx.ctx))); err != nil {
+		return err
+	}
+	u.conf = append(u.conf, f)
+	return nil
+}
+
+// rebuild creates a new context, replays the recorded configuration and
+// the negative trust anchors on it and swaps it in for the current one.
+// The caller must hold u.mu for writing. Resolves in progress are not waited
+// for: they finish on the old context, which is deleted after the last one.
+// The new context starts with an empty cache. After Destroy it returns an
+// error.
+func (u *Unbound) rebuild() error {
+	if u.destroyed {
+		return errors.New("unbound: context is destroyed")
+	}
+	ctx := C.ub_ctx_create()
+	if ctx == nil {
+		return errors.New("unbound: failed to create context")
+	}
+	conf := make([]ctxFunc, 0, len(u.conf)+len(u.nta))
+	conf = append(conf, u.conf...)
+	conf = append(conf, u.ntaConfig()...)
+	for _, f := range conf {
+		if err := newError(int(f(ctx))); err != nil {
+			C.ub_ctx_delete(ctx)
+			return err
+		}
+	}
+	old := u.ctx
+	u.ctx = &ubContext{ctx: ctx}
+	old.retire()
+	return nil
 }
 
 // ResolvConf wraps Unbound's ub_ctx_resolvconf.
 func (u *Unbound) ResolvConf(fname string) error {
-	cfname := C.CString(fname)
-	defer C.free(unsafe.Pointer(cfname))
-	i := C.ub_ctx_resolvconf(u.ctx, cfname)
-	return newError(int(i))
+	return u.apply(func(ctx *C.struct_ub_ctx) C.int {
+		cfname := C.CString(fname)
+		defer C.free(unsafe.Pointer(cfname))
+		return C.ub_ctx_resolvconf(ctx, cfname)
+	})
 }
 
 // SetOption wraps Unbound's ub_ctx_set_option.
 func (u *Unbound) SetOption(opt, val string) error {
-	copt := C.CString(opt)
-	defer C.free(unsafe.Pointer(copt))
-	cval := C.CString(val)
-	defer C.free(unsafe.Pointer(cval))
-	i := C.ub_ctx_set_option(u.ctx, copt, cval)
-	return newError(int(i))
+	return u.apply(setOption(opt, val))
+}
+
+func setOption(opt, val string) ctxFunc {
+	return func(ctx *C.struct_ub_ctx) C.int {
+		copt := C.CString(opt)
+		defer C.free(unsafe.Pointer(copt))
+		cval := C.CString(val)
+		defer C.free(unsafe.Pointer(cval))
+		return C.ub_ctx_set_option(ctx, copt, cval)
+	}
 }
 
 // GetOption wraps Unbound's ub_ctx_get_option.
@@ -176,32 +299,50 @@ func (u *Unbound) GetOption(opt string) (string, error) {
 
 	cval := C.new_char_pointer()
 	defer C.free(unsafe.Pointer(cval))
-	i := C.ub_ctx_get_option(u.ctx, C.CString(opt), &cval)
+	u.mu.RLock()
+	i := C.ub_ctx_get_option(u.ctx.ctx, copt, &cval)
+	u.mu.RUnlock()
 	return C.GoString(cval), newError(int(i))
 }
 
 // Config wraps Unbound's ub_ctx_config.
 func (u *Unbound) Config(fname string) error {
-	cfname := C.CString(fname)
-	defer C.free(unsafe.Pointer(cfname))
-	i := C.ub_ctx_config(u.ctx, cfname)
-	return newError(int(i))
+	return u.apply(func(ctx *C.struct_ub_ctx) C.int {
+		cfname := C.CString(fname)
+		defer C.free(unsafe.Pointer(cfname))
+		return C.ub_ctx_config(ctx, cfname)
+	})
 }
 
 // SetFwd wraps Unbound's ub_ctx_set_fwd.
 func (u *Unbound) SetFwd(addr string) error {
-	caddr := C.CString(addr)
-	defer C.free(unsafe.Pointer(caddr))
-	i := C.ub_ctx_set_fwd(u.ctx, caddr)
-	return newError(int(i))
+	return u.route(Zone{Name: ".", Type: ZoneForward, Addrs: []string{addr}}, true, func(ctx *C.struct_ub_ctx) C.int {
+		caddr := C.CString(addr)
+		defer C.free(unsafe.Pointer(caddr))
+		return C.ub_ctx_set_fwd(ctx, caddr)
+	})
+}
+
+// SetTLS wraps Unbound's ub_ctx_set_tls. The setting is global: it applies to
+// all forwarders and stub zones. Use AddForwardZone or SetFwdTLS to use TLS for
+// a single zone.
+func (u *Unbound) SetTLS(tls bool) error {
+	ctls := C.int(0)
+	if tls {
+		ctls = 1
+	}
+	return u.apply(func(ctx *C.struct_ub_ctx) C.int {
+		return C.ub_ctx_set_tls(ctx, ctls)
+	})
 }
 
 // Hosts wraps Unbound's ub_ctx_hosts.
 func (u *Unbound) Hosts(fname string) error {
-	cfname := C.CString(fname)
-	defer C.free(unsafe.Pointer(cfname))
-	i := C.ub_ctx_hosts(u.ctx, cfname)
-	return newError(int(i))
+	return u.apply(func(ctx *C.struct_ub_ctx) C.int {
+		cfname := C.CString(fname)
+		defer C.free(unsafe.Pointer(cfname))
+		return C.ub_ctx_hosts(ctx, cfname)
+	})
 }
 
 // Resolve wraps Unbound's ub_resolve.
@@ -216,7 +357,14 @@ func (u *Unbound) Resolve(name string, rrtype, rrclass uint16) (*Result, error)
 	// https://github.com/miekg/unbound/issues/8
 	// This is likely related to https://github.com/golang/go/issues/15921
 	t := time.Now()
-	i := C.ub_resolve(u.ctx, cname, C.int(rrtype), C.int(rrclass), &res)
+	// The lock is only held to get the context, so a rebuild does not have
+	// to wait for resolves in progress.
+	u.mu.RLock()
+	ctx := u.ctx
+	ctx.acquire()
+	u.mu.RUnlock()
+	i := C.ub_resolve(ctx.ctx, cname, C.int(rrtype), C.int(rrclass), &res)
+	ctx.release()
 	r.Rtt = time.Since(t)
 	err := newError(int(i))
 	if err != nil {
@@ -290,11 +438,26 @@ func (u *Unbound) Resolve(name string, rrtype, rrclass uint16) (*Result, error)
 	return r, err
 }
 
+// Async wraps Unbound's ub_ctx_async. It only selects whether libunbound uses
+// a thread or a forked process for its own asynchronous queries, which this
+// package never makes: ResolveAsync calls Resolve from a goroutine. New
+// already selects threads, the only supported mode: forking a process that
+// runs the Go runtime is unsafe, so Async(false) returns an error.
+func (u *Unbound) Async(dothread bool) error {
+	if !dothread {
+		return errors.New("unbound: forking a background process is not supported in Go")
+	}
+	return u.apply(func(ctx *C.struct_ub_ctx) C.int {
+		return C.ub_ctx_async(ctx, 1)
+	})
+}
+
 // ResolveAsync does *not* wrap the Unbound function, instead
 // it utilizes Go's goroutines and channels to implement the asynchronous behavior Unbound
 // implements. As a result the function signature is different.
 // The result (or an error) is returned on the channel c.
 // Also the ub_cancel, ub_wait_, ub_fd, ub_process are not implemented.
+// Each call uses one goroutine, independent of the mode set with Async.
 func (u *Unbound) ResolveAsync(name string, rrtype, rrclass uint16, c chan *ResultError) {
 	go func() {
 		r, e := u.Resolve(name, rrtype, rrclass)
@@ -303,76 +466,120 @@ func (u *Unbound) ResolveAsync(name string, rrtype, rrclass uint16, c chan *Resu
 	return
 }
 
-// AddTa wraps Unbound's ub_ctx_add_ta.
+// AddTa wraps Unbound's ub_ctx_add_ta. The anchor is parsed first, malformed
+// anchors and anchors that are already loaded are rejected.
 func (u *Unbound) AddTa(ta string) error {
-	cta := C.CString(ta)
-	i := C.ub_ctx_add_ta(u.ctx, cta)
-	return newError(int(i))
+	anchors, err := parseTa(ta)
+	if err != nil {
+		return err
+	}
+	return u.addTrustAnchors(anchors, addTa(anchors))
 }
 
-// AddTaFile wraps Unbound's ub_ctx_add_ta_file.
+// AddTaFile replaces Unbound's ub_ctx_add_ta_file. The file is parsed in Go,
+// malformed anchors and anchors that are already loaded are rejected, and the
+// anchors are added one by one with ub_ctx_add_ta, so the file is only read
+// once.
 func (u *Unbound) AddTaFile(fname string) error {
-	cfname := C.CString(fname)
-	defer C.free(unsafe.Pointer(cfname))
-	i := C.ub_ctx_add_ta_file(u.ctx, cfname)
-	return newError(int(i))
+	anchors, err := parseTaFile(fname)
+	if err != nil {
+		return err
+	}
+	return u.addTrustAnchors(anchors, addTa(anchors))
 }
 
-// TrustedKeys wraps Unbound's ub_ctx_trustedkeys.
+// TrustedKeys replaces Unbound's ub_ctx_trustedkeys. The file is parsed in
+// Go, malformed anchors and anchors that are already loaded are rejected, and
+// the anchors are added one by one with ub_ctx_add_ta, so the file is only
+// read once. Like ub_ctx_trustedkeys, only trusted-keys clauses are read;
+// managed-keys and trust-anchors clauses are an error.
 func (u *Unbound) TrustedKeys(fname string) error {
-	cfname := C.CString(fname)
-	defer C.free(unsafe.Pointer(cfname))
-	i := C.ub_ctx_trustedkeys(u.ctx, cfname)
-	return newError(int(i))
+	anchors, err := parseTrustedKeys(fname)
+	if err != nil {
+		return err
+	}
+	return u.addTrustAnchors(anchors, addTa(anchors))
+}
+
+// addTa returns the configuration call adding ta with ub_ctx_add_ta. The
+// call replays the parsed anchors, not their source, so the context always
+// holds the anchors returned by TrustAnchors.
+func addTa(ta []TrustAnchor) ctxFunc {
+	rrs := make([]string, len(ta))
+	for i := range ta {
+		rrs[i] = ta[i].RR.String()
+	}
+	return func(ctx *C.struct_ub_ctx) C.int {
+		for _, rr := range rrs {
+			cta := C.CString(rr)
+			i := C.ub_ctx_add_ta(ctx, cta)
+			C.free(unsafe.Pointer(cta))
+			if i != 0 {
+				return i
+			}
+		}
+		return 0
+	}
 }
 
 // ZoneAdd wraps Unbound's ub_ctx_zone_add.
 func (u *Unbound) ZoneAdd(zoneName, zoneType string) error {
-	czoneName := C.CString(zoneName)
-	defer C.free(unsafe.Pointer(czoneName))
-	czoneType := C.CString(zoneType)
-	defer C.free(unsafe.Pointer(czoneType))
-	i := C.ub_ctx_zone_add(u.ctx, czoneName, czoneType)
-	return newError(int(i))
+	return u.apply(func(ctx *C.struct_ub_ctx) C.int {
+		czoneName := C.CString(zoneName)
+		defer C.free(unsafe.Pointer(czoneName))
+		czoneType := C.CString(zoneType)
+		defer C.free(unsafe.Pointer(czoneType))
+		return C.ub_ctx_zone_add(ctx, czoneName, czoneType)
+	})
 }
 
 // ZoneRemove wraps Unbound's ub_ctx_zone_remove.
 func (u *Unbound) ZoneRemove(zoneName string) error {
-	czoneName := C.CString(zoneName)
-	defer C.free(unsafe.Pointer(czoneName))
-	i := C.ub_ctx_zone_remove(u.ctx, czoneName)
-	return newError(int(i))
+	return u.apply(func(ctx *C.struct_ub_ctx) C.int {
+		czoneName := C.CString(zoneName)
+		defer C.free(unsafe.Pointer(czoneName))
+		return C.ub_ctx_zone_remove(ctx, czoneName)
+	})
 }
 
 // DataAdd wraps Unbound's ub_ctx_data_add.
 func (u *Unbound) DataAdd(data string) error {
-	cdata := C.CString(data)
-	defer C.free(unsafe.Pointer(cdata))
-	i := C.ub_ctx_data_add(u.ctx, cdata)
-	return newError(int(i))
+	return u.apply(func(ctx *C.struct_ub_ctx) C.int {
+		cdata := C.CString(data)
+		defer C.free(unsafe.Pointer(cdata))
+		return C.ub_ctx_data_add(ctx, cdata)
+	})
 }
 
 // DataRemove wraps Unbound's ub_ctx_data_remove.
 func (u *Unbound) DataRemove(data string) error {
-	cdata := C.CString(data)
-	defer C.free(unsafe.Pointer(cdata))
-	i := C.ub_ctx_data_remove(u.ctx, cdata)
-	return newError(int(i))
+	return u.apply(func(ctx *C.struct_ub_ctx) C.int {
+		cdata := C.CString(data)
+		defer C.free(unsafe.Pointer(cdata))
+		return C.ub_ctx_data_remove(ctx, cdata)
+	})
 }
 
 // DebugOut wraps Unbound's ub_ctx_debugout.
 func (u *Unbound) DebugOut(out *os.File) error {
 	cmode := C.CString("a+")
 	defer C.free(unsafe.Pointer(cmode))
+	// The stream is opened once and shared by the contexts created by
+	// rebuild, so replaying the call does not open it again.
 	file := C.fdopen(C.int(out.Fd()), cmode)
-	i := C.ub_ctx_debugout(u.ctx, unsafe.Pointer(file))
-	return newError(int(i))
+	if file == nil {
+		return errors.New("unbound: failed to open debug output: " + out.Name())
+	}
+	return u.apply(func(ctx *C.struct_ub_ctx) C.int {
+		return C.ub_ctx_debugout(ctx, unsafe.Pointer(file))
+	})
 }
 
 // DebugLevel wraps Unbound's ub_ctx_data_level.
 func (u *Unbound) DebugLevel(d int) error {
-	i := C.ub_ctx_debuglevel(u.ctx, C.int(d))
-	return newError(int(i))
+	return u.apply(func(ctx *C.struct_ub_ctx) C.int {
+		return C.ub_ctx_debuglevel(ctx, C.int(d))
+	})
 }
 
 // Version wrap Ubounds's ub_version. Return the version of the Unbound
@@ -3,6 +3,7 @@ package unbound
 import (
 	"fmt"
 	"runtime"
+	"strings"
 	"sync"
 	"testing"
 
@@ -129,3 +130,69 @@ func TestStress(t *testing.T) {
 	wg.Wait()
 	runtime.GOMAXPROCS(procs)
 }
+
+func TestAsync(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+	// New selects threads through apply, so a rebuild replays it.
+	if len(u.conf) != 1 {
+		t.Fatalf("expected New to record the thread mode, got %d recorded calls", len(u.conf))
+	}
+	u.mu.Lock()
+	err := u.rebuild()
+	u.mu.Unlock()
+	if err != nil {
+		t.Fatalf("failed to replay thread mode: %s", err)
+	}
+	if err := u.Async(false); err == nil {
+		t.Fatal("expected error when selecting fork mode")
+	}
+	if err := u.Async(true); err != nil {
+		t.Fatalf("failed to select thread mode: %s", err)
+	}
+}
+
+func TestLookupSRVAddrs(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+	for _, rr := range []string{
+		`_sip._udp.example.com. SRV 10 0 5060 sip1.example.com.`,
+		`_sip._udp.example.com. SRV 20 0 5061 sip2.example.com.`,
+		`_sip._udp.example.com. SRV 30 0 5062 noaddr.example.com.`,
+		`sip1.example.com. A 192.0.2.1`,
+		`sip2.example.com. AAAA 2001:db8::2`,
+	} {
+		if err := u.DataAdd(rr); err != nil {
+			t.Fatalf("failed to add local data: %s", err)
+		}
+	}
+	if _, err := u.Resolve("_sip._udp.example.com.", dns.TypeSRV, dns.ClassINET); err != nil {
+		t.Skipf("can not resolve local data: %s", err)
+	}
+
+	cname, srv, err := u.LookupSRV("sip", "udp", "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cname != "_sip._udp.example.com." || len(srv) != 3 {
+		t.Errorf("unexpected answer: %s %v", cname, srv)
+	}
+
+	addrs, err := u.LookupSRVAddrs("sip", "udp", "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 2 || addrs[0] != "192.0.2.1:5060" || addrs[1] != "[2001:db8::2]:5061" {
+		t.Errorf("unexpected addresses: %v", addrs)
+	}
+}
+
+func TestLookupIPError(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+	// A label longer than 63 octets makes ub_resolve fail.
+	addrs, err := u.LookupIP(strings.Repeat("a", 70) + ".example.")
+	if err == nil || len(addrs) != 0 {
+		t.Errorf("expected error, got %v, %v", addrs, err)
+	}
+}
@@ -0,0 +1,162 @@
+package unbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Bounds of the delay between polls of WaitForTXT. A delay is never shorter
+// than the TTL of the answer polled, because Unbound caches it for that long.
+const (
+	waitTXTMinDelay = time.Second
+	waitTXTMaxDelay = 32 * time.Second
+)
+
+// WaitForTXTOptions are the options of WaitForTXTWithOptions.
+type WaitForTXTOptions struct {
+	// Authoritative also queries each authoritative server of the zone of
+	// the name directly, see FindAuthoritativeServers. All of them must have
+	// the record.
+	Authoritative bool
+	Port          int // Port the authoritative servers are queried on, 53 when zero
+}
+
+// WaitForTXT waits until a TXT record of name has the value value, e.g. for
+// an ACME DNS-01 challenge. It polls with LookupTXT, backing off
+// exponentially, but never polling again before the TTL of the previous
+// answer has expired. WaitForTXT returns when the value has been observed,
+// or with an error wrapping the context's error when ctx is done first.
+// This method is not found in Unbound.
+func (u *Unbound) WaitForTXT(ctx context.Context, name, value string) error {
+	return u.WaitForTXTWithOptions(ctx, name, value, WaitForTXTOptions{})
+}
+
+// WaitForTXTWithOptions is WaitForTXT with options, e.g. to wait until the
+// value is also observed on each authoritative server.
+// This method is not found in Unbound.
+func (u *Unbound) WaitForTXTWithOptions(ctx context.Context, name, value string, opts WaitForTXTOptions) error {
+	name = dns.Fqdn(name)
+	port := "53"
+	if opts.Port != 0 {
+		port = strconv.Itoa(opts.Port)
+	}
+	var servers []AuthServer
+	if opts.Authoritative {
+		var err error
+		if servers, err = u.FindAuthoritativeServers(name); err != nil {
+			return err
+		}
+		if len(servers) == 0 {
+			return errors.New("unbound: no authoritative servers for: " + name)
+		}
+	}
+
+	backoff := waitTXTMinDelay
+	for {
+		var (
+			ttl time.Duration
+			err error
+		)
+		for _, s := range servers {
+			if err = authTXT(ctx, s, port, name, value); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			if ttl, err = u.resolverTXT(name, value); err == nil {
+				return nil
+			}
+		}
+
+		delay := backoff
+		if ttl > delay {
+			delay = ttl
+		}
+		if backoff *= 2; backoff > waitTXTMaxDelay {
+			backoff = waitTXTMaxDelay
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("unbound: waiting for TXT record %q at %s: %s: %w", value, name, err, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+// resolverTXT returns nil if the TXT records of name include value. If not,
+// it returns the time the answer remains cached, and the reason.
+func (u *Unbound) resolverTXT(name, value string) (time.Duration, error) {
+	r, txt, err := u.lookupTXT(name)
+	if err != nil {
+		return 0, err
+	}
+	if hasTXT(txt, value) {
+		return 0, nil
+	}
+	ttl := time.Duration(r.Ttl) * time.Second
+	if r.AnswerPacket != nil && len(txt) == 0 {
+		// Negative answers are cached for the TTL of the SOA record in
+		// the authority section, or its minimum field when that is lower,
+		// RFC 2308 section 5.
+		for _, rr := range r.AnswerPacket.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				ttl = time.Duration(soa.Hdr.Ttl) * time.Second
+				if m := time.Duration(soa.Minttl) * time.Second; m < ttl {
+					ttl = m
+				}
+			}
+		}
+	}
+	return ttl, errors.New("resolver does not have the record")
+}
+
+// authTXT queries the authoritative server s on port for the TXT records of
+// name, and returns nil if they include value. The addresses of s are tried in turn
+// until one answers.
+func authTXT(ctx context.Context, s AuthServer, port, name, value string) error {
+	if len(s.Addrs) == 0 {
+		return errors.New("no addresses for " + s.Name)
+	}
+	m := new(dns.Msg)
+	m.SetQuestion(name, dns.TypeTXT)
+	m.RecursionDesired = false
+	c := new(dns.Client)
+	var err error
+	for _, ip := range s.Addrs {
+		var r *dns.Msg
+		r, _, err = c.ExchangeContext(ctx, m, net.JoinHostPort(ip.String(), port))
+		if err != nil {
+			continue
+		}
+		var txt []*dns.TXT
+		for _, rr := range r.Answer {
+			if x, ok := rr.(*dns.TXT); ok {
+				txt = append(txt, x)
+			}
+		}
+		if hasTXT(txt, value) {
+			return nil
+		}
+		return errors.New(s.Name + " does not have the record")
+	}
+	return fmt.Errorf("querying %s: %s", s.Name, err)
+}
+
+// hasTXT returns true if one of the records in txt has the value value.
+func hasTXT(txt []*dns.TXT, value string) bool {
+	for _, rr := range txt {
+		if strings.Join(rr.Txt, "") == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package unbound
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestAuthTXT(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Authoritative = true
+		if req.Question[0].Name == "_acme-challenge.example.com." {
+			txt, _ := dns.NewRR(`_acme-challenge.example.com. 60 IN TXT "token"`)
+			m.Answer = append(m.Answer, txt)
+		}
+		w.WriteMsg(m)
+	})}
+	go s.ActivateAndServe()
+	defer s.Shutdown()
+
+	_, port, _ := net.SplitHostPort(pc.LocalAddr().String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server := AuthServer{Name: "ns.example.com.", Addrs: []net.IP{net.ParseIP("127.0.0.1")}}
+	if err := authTXT(ctx, server, port, "_acme-challenge.example.com.", "token"); err != nil {
+		t.Errorf("expected record to be found: %s", err)
+	}
+	if err := authTXT(ctx, server, port, "_acme-challenge.example.com.", "other"); err == nil {
+		t.Error("expected error for other value")
+	}
+	if err := authTXT(ctx, server, port, "_acme-challenge.example.org.", "token"); err == nil {
+		t.Error("expected error for missing record")
+	}
+	if err := authTXT(ctx, AuthServer{Name: "ns.example.com."}, port, "_acme-challenge.example.com.", "token"); err == nil {
+		t.Error("expected error for server without addresses")
+	}
+}
+
+func TestWaitForTXT(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+	if err := u.DataAdd(`_acme-challenge.example.com. TXT "token"`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Resolve("_acme-challenge.example.com.", dns.TypeTXT, dns.ClassINET); err != nil {
+		t.Skipf("can not resolve local data: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := u.WaitForTXT(ctx, "_acme-challenge.example.com", "token"); err != nil {
+		t.Errorf("expected record to be found: %s", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := u.WaitForTXT(ctx, "_acme-challenge.example.com", "other")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package unbound
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Bounds of the refresh interval of Watch. The interval is the TTL of the
+// answer clamped to these bounds, plus up to 10% jitter.
+const (
+	watchMinRefresh = 5 * time.Second
+	watchMaxRefresh = time.Hour
+)
+
+// Change is a change in the records watched by Watch.
+type Change struct {
+	Added   []dns.RR
+	Removed []dns.RR
+	Result  *Result // The answer that caused the change, nil if Err is set
+	Err     error
+}
+
+// Watch resolves name and qtype, class INET, and re-resolves it when the TTL
+// of the answer expires. The first Change holds all records as added, later
+// ones only hold the records added and removed since the previous answer;
+// records are compared without their TTL. A failed resolve is sent as a
+// Change with Err set, the records are then kept and resolving is retried
+// with exponential backoff. The channel is closed when ctx is done.
+// This method is not found in Unbound.
+func (u *Unbound) Watch(ctx context.Context, name string, qtype uint16) <-chan Change {
+	c := make(chan Change)
+	go u.watch(ctx, dns.Fqdn(name), qtype, c)
+	return c
+}
+
+func (u *Unbound) watch(ctx context.Context, name string, qtype uint16, c chan<- Change) {
+	defer close(c)
+	var (
+		current map[string]dns.RR
+		backoff = watchMinRefresh
+	)
+	for {
+		var (
+			ch    Change
+			send  bool
+			delay time.Duration
+		)
+		r, err := u.Resolve(name, qtype, dns.ClassINET)
+		switch {
+		case err != nil:
+		case r.Bogus:
+			err = fmt.Errorf("unbound: %s records are bogus for %s: %s", dns.TypeToString[qtype], name, r.WhyBogus)
+		case r.Rcode != dns.RcodeSuccess && r.Rcode != dns.RcodeNameError:
+			err = fmt.Errorf("unbound: %s lookup for %s failed: %s", dns.TypeToString[qtype], name, dns.RcodeToString[r.Rcode])
+		}
+		if err != nil {
+			ch.Err, send = err, true
+			delay = backoff
+			if backoff *= 2; backoff > watchMaxRefresh {
+				backoff = watchMaxRefresh
+			}
+		} else {
+			next := rrSet(r.Rr)
+			ch.Added, ch.Removed = diffRRSet(current, next)
+			ch.Result = r
+			send = current == nil || len(ch.Added) > 0 || len(ch.Removed) > 0
+			current = next
+			backoff = watchMinRefresh
+			delay = watchRefresh(time.Duration(r.Ttl) * time.Second)
+		}
+
+		if send {
+			select {
+			case c <- ch:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// watchRefresh returns the refresh interval for an answer with the TTL ttl.
+func watchRefresh(ttl time.Duration) time.Duration {
+	if ttl < watchMinRefresh {
+		ttl = watchMinRefresh
+	}
+	if ttl > watchMaxRefresh {
+		ttl = watchMaxRefresh
+	}
+	return ttl + time.Duration(rand.Int63n(int64(ttl/10)+1))
+}
+
+// rrSet returns the records in rrs keyed on their text without the TTL.
+func rrSet(rrs []dns.RR) map[string]dns.RR {
+	set := make(map[string]dns.RR, len(rrs))
+	for _, rr := range rrs {
+		x := dns.Copy(rr)
+		x.Header().Ttl = 0
+		set[x.String()] = rr
+	}
+	return set
+}
+
+// diffRRSet returns the records in next not in prev, and those in prev not in
+// next.
+func diffRRSet(prev, next map[string]dns.RR) (added, removed []dns.RR) {
+	for k, rr := range next {
+		if _, ok := prev[k]; !ok {
+			added = append(added, rr)
+		}
+	}
+	for k, rr := range prev {
+		if _, ok := next[k]; !ok {
+			removed = append(removed, rr)
+		}
+	}
+	return added, removed
+}
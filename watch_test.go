@@ -0,0 +1,80 @@
+package unbound
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestDiffRRSet(t *testing.T) {
+	rr := func(s string) dns.RR {
+		x, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return x
+	}
+	prev := rrSet([]dns.RR{rr("example.com. 300 IN A 192.0.2.1"), rr("example.com. 300 IN A 192.0.2.2")})
+	next := rrSet([]dns.RR{rr("example.com. 60 IN A 192.0.2.2"), rr("example.com. 60 IN A 192.0.2.3")})
+
+	added, removed := diffRRSet(prev, next)
+	if len(added) != 1 || added[0].(*dns.A).A.String() != "192.0.2.3" {
+		t.Errorf("unexpected added records: %v", added)
+	}
+	if len(removed) != 1 || removed[0].(*dns.A).A.String() != "192.0.2.1" {
+		t.Errorf("unexpected removed records: %v", removed)
+	}
+	if added, removed := diffRRSet(next, next); len(added) != 0 || len(removed) != 0 {
+		t.Errorf("expected no changes, got %v and %v", added, removed)
+	}
+}
+
+func TestWatchRefresh(t *testing.T) {
+	tests := []struct {
+		ttl, min, max time.Duration
+	}{
+		{0, watchMinRefresh, watchMinRefresh * 11 / 10},
+		{time.Minute, time.Minute, time.Minute * 11 / 10},
+		{24 * time.Hour, watchMaxRefresh, watchMaxRefresh * 11 / 10},
+	}
+	for _, tc := range tests {
+		for i := 0; i < 100; i++ {
+			if d := watchRefresh(tc.ttl); d < tc.min || d > tc.max {
+				t.Fatalf("refresh for TTL %s out of bounds: %s", tc.ttl, d)
+			}
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	u := New()
+	defer u.Destroy()
+	if err := u.DataAdd("watch.example. A 192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Resolve("watch.example.", dns.TypeA, dns.ClassINET); err != nil {
+		t.Skipf("can not resolve local data: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := u.Watch(ctx, "watch.example", dns.TypeA)
+	select {
+	case ch := <-c:
+		if ch.Err != nil || len(ch.Added) != 1 || len(ch.Removed) != 0 {
+			t.Errorf("unexpected first change: %+v", ch)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for first change")
+	}
+	cancel()
+	select {
+	case _, ok := <-c:
+		if ok {
+			t.Error("expected channel to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for channel to be closed")
+	}
+}